@@ -0,0 +1,73 @@
+package dockerfile
+
+import "github.com/dekkagaijin/go-dockerfile/internal/parser"
+
+// Directive returns the value of the parser directive with the given key, if set.
+func (p *Parsed) Directive(key string) (string, bool) {
+	for _, d := range p.Directives {
+		if d.Key == key {
+			return d.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetDirective sets the value of the parser directive with the given key, preserving its position if it was already
+// set. An empty value removes the directive. Setting the `escape` directive also updates EscapeCharacter, and fails if
+// the value is not a valid escape character, see parser.ParseEscapeCharacter.
+func (p *Parsed) SetDirective(key, value string) error {
+	if key == EscapeParserDirectiveKey {
+		escapeCharacter := DefaultExcapeCharacter
+		if value != "" {
+			var err error
+			if escapeCharacter, err = parser.ParseEscapeCharacter(value); err != nil {
+				return err
+			}
+		}
+		p.EscapeCharacter = escapeCharacter
+	}
+	p.setDirective(key, value)
+	return nil
+}
+
+// setDirective sets the value of a parser directive whose value does not need to be validated, see SetDirective.
+func (p *Parsed) setDirective(key, value string) {
+	for i, d := range p.Directives {
+		if d.Key != key {
+			continue
+		}
+		if value == "" {
+			p.Directives = append(p.Directives[:i], p.Directives[i+1:]...)
+		} else {
+			p.Directives[i].Value = value
+		}
+		return
+	}
+	if value != "" {
+		p.Directives = append(p.Directives, Directive{Key: key, Value: value})
+	}
+}
+
+// Syntax is the value of the `syntax` directive, the frontend image used to build the Dockerfile, if set.
+// See: https://docs.docker.com/engine/reference/builder/#syntax
+func (p *Parsed) Syntax() string {
+	val, _ := p.Directive(SyntaxParserDirectiveKey)
+	return val
+}
+
+// SetSyntax sets the `syntax` directive, or removes it if empty.
+func (p *Parsed) SetSyntax(syntax string) {
+	p.setDirective(SyntaxParserDirectiveKey, syntax)
+}
+
+// Check is the value of the `check` directive, which configures build checks, if set.
+// See: https://docs.docker.com/engine/reference/builder/#check
+func (p *Parsed) Check() string {
+	val, _ := p.Directive(CheckParserDirectiveKey)
+	return val
+}
+
+// SetCheck sets the `check` directive, or removes it if empty.
+func (p *Parsed) SetCheck(check string) {
+	p.setDirective(CheckParserDirectiveKey, check)
+}
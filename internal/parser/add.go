@@ -2,11 +2,19 @@ package parser
 
 import (
 	"fmt"
-	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
+/*
+ADD is an instruction of the forms:
+
+	`ADD [--chown=<user>:<group>] [--chmod=<perms>] [--checksum=<checksum>] [--keep-git-dir] [--link] [--exclude=<pattern>...] <src>... <dest>`
+	`ADD [<flags>...] ["<src>",... "<dest>"]`
+
+See: https://docs.docker.com/engine/reference/builder/#add
+*/
 func scanADD(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
 	inst := &statement.AddInstruction{}
 	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
@@ -17,16 +25,55 @@ func scanADD(lines []string, escapeCharacter rune) (stmt statement.Statement, re
 		return nil, lines, fmt.Errorf("not an ADD statement: %q", statementLines[0])
 	}
 	inst.Lines = statementLines
+
+	flags, rawArgs, err := scanFlags(rawArgs, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	for _, f := range flags {
+		switch f.Name {
+		case "chown":
+			inst.Chown, err = requireFlagValue(st, f)
+		case "chmod":
+			inst.Chmod, err = requireFlagValue(st, f)
+		case "checksum":
+			inst.Checksum, err = requireFlagValue(st, f)
+		case "keep-git-dir":
+			inst.KeepGitDir, err = parseBoolFlag(st, f)
+		case "link":
+			inst.Link, err = parseBoolFlag(st, f)
+		case "exclude":
+			var pattern string
+			pattern, err = requireFlagValue(st, f)
+			inst.Exclude = append(inst.Exclude, pattern)
+		default:
+			err = unknownFlagError(st, f)
+		}
+		if err != nil {
+			return nil, lines, err
+		}
+	}
+	if inst.FlagOrder, err = flagOrder(st, flags, "exclude"); err != nil {
+		return nil, lines, err
+	}
+
 	if args, err := parseJSONStringList(rawArgs); err == nil {
 		inst.Args = statement.Arguments{
 			List:     args,
 			Execable: true,
 		}
 	} else {
+		words, err := shell.NewLexer(escapeCharacter).Lex(rawArgs)
+		if err != nil {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "%v", err)
+		}
 		inst.Args = statement.Arguments{
-			List:     strings.Fields(rawArgs),
+			List:     shell.Raw(words),
 			Execable: false,
 		}
+		if inst.Args.Heredocs, remainingLines, err = scanHeredocs(inst.Args.List, remainingLines); err != nil {
+			return nil, lines, err
+		}
 	}
 	return inst, remainingLines, nil
 }
@@ -3,45 +3,97 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
-const (
-	reEscapedCharacter = `(?:\\.)`
-	reSingleQuotedVal  = `(?:'` + `(?:\'|[^'])*` + `')`
-	reDoubleQuotedVal  = `(?:"` + `(?:\"|[^"])*` + `")`
-	reUnquotedVal      = (`(?:` +
+// wordPatterns are the regular expressions matching the parts of shell words, in which the escape character escapes
+// the character following it.
+type wordPatterns struct {
+	escapedCharacter string
+	singleQuotedVal  string
+	doubleQuotedVal  string
+	unquotedVal      string
+	keyValuePair     string
+	// word is a shell word, made up of any mix of quoted, escaped and plain characters.
+	word string
+	// plainCharacter is a character which is neither a quote, the escape character nor whitespace.
+	plainCharacter string
+}
+
+func newWordPatterns(escapeCharacter rune) wordPatterns {
+	esc := regexp.QuoteMeta(string(escapeCharacter))
+	p := wordPatterns{
+		escapedCharacter: `(?:` + esc + `.)`,
+		singleQuotedVal:  `(?:'` + `[^']*` + `')`,
+		doubleQuotedVal:  `(?:"` + `(?:` + esc + `.|[^"` + esc + `])*` + `")`,
+		plainCharacter:   `[^'"` + esc + `[:space:]]`,
+	}
+	p.unquotedVal = (`(?:` +
 		(`(?:` +
-			reEscapedCharacter +
+			p.escapedCharacter +
 			`|` + // or
 			`[[:^space:]]` + // not whitespace
 			`)+`) +
 		`)`)
-	reKeyValuePair = ("(?:" +
+	p.keyValuePair = ("(?:" +
 		("(" + reNotWhitespaceOrEquals + ")") + // key
 		"=" +
 		("(" +
-			reSingleQuotedVal +
+			p.singleQuotedVal +
 			"|" + // or
-			reDoubleQuotedVal +
+			p.doubleQuotedVal +
 			"|" + // or
-			reUnquotedVal + // unquoted (possibly escaped) val
+			p.unquotedVal + // unquoted (possibly escaped) val
 			")") +
 		")")
-)
+	p.word = ("(?:" +
+		p.singleQuotedVal +
+		"|" + // or
+		p.doubleQuotedVal +
+		"|" + // or
+		p.escapedCharacter +
+		"|" + // or
+		p.plainCharacter +
+		")+")
+	return p
+}
 
-var argInstructionArgsMatcher = regexp.MustCompile(
-	reStartOfLine +
+// escapeAwareMatcher is a regular expression compiled for each of the valid escape characters.
+type escapeAwareMatcher map[rune]*regexp.Regexp
+
+// newEscapeAwareMatcher compiles the regular expression built from the word patterns of each valid escape character.
+func newEscapeAwareMatcher(pattern func(p wordPatterns) string) escapeAwareMatcher {
+	m := escapeAwareMatcher{}
+	for _, escapeCharacter := range []rune{DefaultExcapeCharacter, WindowsEscapeCharacter} {
+		m[escapeCharacter] = regexp.MustCompile(pattern(newWordPatterns(escapeCharacter)))
+	}
+	return m
+}
+
+// with is the regular expression for the given escape character.
+func (m escapeAwareMatcher) with(escapeCharacter rune) *regexp.Regexp {
+	if re, ok := m[escapeCharacter]; ok {
+		return re
+	}
+	return m[DefaultExcapeCharacter]
+}
+
+var argDeclarationMatcher = newEscapeAwareMatcher(func(p wordPatterns) string {
+	return reStartOfLine +
+		"(" + reNotWhitespaceOrEquals + ")" + // arg name
 		"(?:" +
-		reKeyValuePair +
-		"|" + // or
-		"(" + reNotWhitespaceOrEquals + ")" + // just an arg name
-		")" +
-		reEndOfLine)
+		"(=)" + // has default
+		"(" + p.word + "|" + ")" + // default value, possibly empty
+		")?" +
+		"(?:" + reWhitespace + "|" + reEndOfLine + ")" // whitespace or EOL
+})
 
 // ARG is an instruction of the form:
-// `ARG <name>[=<default value>]`
+// `ARG <name>[=<default value>] [<name>[=<default value>]...]`
+// Default values may be quoted or escaped following the same rules as ENV.
+// `ARG <name>=` declares an empty default, which is distinct from having no default.
 // See: https://docs.docker.com/engine/reference/builder/#arg
 func scanARG(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
 	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
@@ -51,18 +103,29 @@ func scanARG(lines []string, escapeCharacter rune) (stmt statement.Statement, re
 	if st != statement.ARG {
 		return nil, lines, fmt.Errorf("not an ARG statement: %q", statementLines[0])
 	}
-
-	reMatches := argInstructionArgsMatcher.FindStringSubmatch(rawArgs)
-	if len(reMatches) == 0 {
-		return nil, lines, fmt.Errorf("syntax error, ARG args must be of the form `<name>[=<default value>]`: %q", rawArgs)
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
 	}
 
-	inst := &statement.ArgInstruction{
-		Name:       reMatches[1],
-		DefaultVal: reMatches[2],
+	rawArgs = strings.TrimSpace(rawArgs)
+	if rawArgs == "" {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, "", "ARG requires at least one argument")
 	}
-	if reMatches[3] != "" {
-		inst.Name = reMatches[3]
+
+	inst := &statement.ArgInstruction{}
+	unparsed := rawArgs
+	for unparsed != "" {
+		reMatches := argDeclarationMatcher.with(escapeCharacter).FindStringSubmatch(unparsed)
+		if len(reMatches) == 0 {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, unparsed, "syntax error, ARG args must be of the form `<name>[=<default value>]`: %q", unparsed)
+		}
+		inst.Declarations = append(inst.Declarations, statement.ArgDeclaration{
+			Name:       reMatches[1],
+			HasDefault: reMatches[2] != "",
+			Default:    statement.Unquote(reMatches[3], escapeCharacter),
+			RawDefault: reMatches[3],
+		})
+		unparsed = unparsed[len(reMatches[0]):]
 	}
 	return inst, remainingLines, nil
 }
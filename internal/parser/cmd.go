@@ -1,8 +1,7 @@
 package parser
 
 import (
-	"strings"
-
+	"github.com/dekkagaijin/go-dockerfile/shell"
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
@@ -14,6 +13,9 @@ func scanCMD(lines []string, escapeCharacter rune) (stmt statement.Statement, re
 	}
 	inst.InstructionType = st
 	inst.Lines = statementLines
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
 	if args, err := parseJSONStringList(rawArgs); err == nil {
 		inst.Args = statement.Arguments{
 			List:     args,
@@ -21,7 +23,7 @@ func scanCMD(lines []string, escapeCharacter rune) (stmt statement.Statement, re
 		}
 	} else {
 		inst.Args = statement.Arguments{
-			List:     strings.Fields(rawArgs),
+			List:     shell.NewLexer(escapeCharacter).Fields(rawArgs),
 			Execable: false,
 		}
 	}
@@ -1,29 +1,81 @@
 package parser
 
 import (
-	"strings"
+	"fmt"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
+/*
+COPY instructions copy files into the image. There are 2 forms:
+
+	a) `COPY [--<flag>...] <src>... <dest>`
+	b) `COPY [--<flag>...] ["<src>",... "<dest>"]`
+
+Paths containing whitespace must be quoted or escaped in the first form, and are kept as written.
+The sources of the first form may also be heredocs.
+
+See: https://docs.docker.com/engine/reference/builder/#copy
+*/
 func scanCOPY(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	inst := &statement.GenericInstruction{InstructionType: statement.COPY}
 	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
 	if err != nil {
 		return nil, lines, err
 	}
-	inst.InstructionType = st
-	inst.Lines = statementLines
-	if args, err := parseJSONStringList(rawArgs); err == nil {
-		inst.Args = statement.Arguments{
-			List:     args,
-			Execable: true,
+	if st != statement.COPY {
+		return nil, lines, fmt.Errorf("not a COPY statement: %q", statementLines[0])
+	}
+	inst := &statement.CopyInstruction{Lines: statementLines}
+
+	flags, rawArgs, err := scanFlags(rawArgs, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	for _, f := range flags {
+		switch f.Name {
+		case "from":
+			inst.From, err = requireFlagValue(st, f)
+		case "chown":
+			inst.Chown, err = requireFlagValue(st, f)
+		case "chmod":
+			inst.Chmod, err = requireFlagValue(st, f)
+		case "link":
+			inst.Link, err = parseBoolFlag(st, f)
+		case "parents":
+			inst.Parents, err = parseBoolFlag(st, f)
+		case "exclude":
+			var pattern string
+			pattern, err = requireFlagValue(st, f)
+			inst.Exclude = append(inst.Exclude, pattern)
+		default:
+			err = unknownFlagError(st, f)
 		}
+		if err != nil {
+			return nil, lines, err
+		}
+	}
+	if inst.FlagOrder, err = flagOrder(st, flags, "exclude"); err != nil {
+		return nil, lines, err
+	}
+
+	var paths []string
+	if paths, err = parseJSONStringList(rawArgs); err == nil {
+		inst.Execable = true
 	} else {
-		inst.Args = statement.Arguments{
-			List:     strings.Fields(rawArgs),
-			Execable: false,
+		words, err := shell.NewLexer(escapeCharacter).Lex(rawArgs)
+		if err != nil {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "%v", err)
+		}
+		paths = shell.Raw(words)
+		if inst.Heredocs, remainingLines, err = scanHeredocs(paths, remainingLines); err != nil {
+			return nil, lines, err
 		}
 	}
+	if len(paths) < 2 {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "COPY requires at least one source and a destination: %q", rawArgs)
+	}
+	inst.Sources = paths[:len(paths)-1]
+	inst.Dest = paths[len(paths)-1]
 	return inst, remainingLines, nil
 }
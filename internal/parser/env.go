@@ -1,19 +1,18 @@
 package parser
 
 import (
-	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"unicode"
 
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
-var envArgMatcher = regexp.MustCompile(
-	reStartOfLine +
-		reKeyValuePair + // one k-v pair at beginning of line
-		"(?:" + reWhitespace + "|" + reEndOfLine + ")") // whitespace or EOL
+var envArgMatcher = newEscapeAwareMatcher(func(p wordPatterns) string {
+	return reStartOfLine +
+		p.keyValuePair + // one k-v pair at beginning of line
+		"(?:" + reWhitespace + "|" + reEndOfLine + ")" // whitespace or EOL
+})
 
 /*
 ENV instructions declare environment variables in the container's context.
@@ -51,13 +50,16 @@ func scanENV(lines []string, escapeCharacter rune) (stmt statement.Statement, re
 	if st != statement.ENV {
 		return nil, lines, fmt.Errorf("not an ENV statement: %q", statementLines[0])
 	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
 
 	rawArgs = strings.TrimSpace(rawArgs)
 	if rawArgs == "" {
-		return nil, lines, errors.New("ENV requires arguments")
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, "", "ENV requires arguments")
 	}
 
-	if !envArgMatcher.MatchString(rawArgs) {
+	if !envArgMatcher.with(escapeCharacter).MatchString(rawArgs) {
 		// This is the legacy form
 		key := strings.Fields(rawArgs)[0]
 		rawVal := rawArgs[len(key):]
@@ -72,9 +74,9 @@ func scanENV(lines []string, escapeCharacter rune) (stmt statement.Statement, re
 	}
 	unparsed := rawArgs
 	for unparsed != "" {
-		argMatch := envArgMatcher.FindStringSubmatch(unparsed)
+		argMatch := envArgMatcher.with(escapeCharacter).FindStringSubmatch(unparsed)
 		if len(argMatch) == 0 {
-			return nil, lines, fmt.Errorf("could not parse next `key=value`: %q", unparsed)
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, unparsed, "could not parse next `key=value`: %q", unparsed)
 		}
 		key := argMatch[1]
 		inst.Env[key] = argMatch[2]
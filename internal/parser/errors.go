@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// ErrorCode is a machine-readable identifier for the kind of a ParseError.
+type ErrorCode string
+
+const (
+	EmptyFileErrorCode           ErrorCode = "empty-file"
+	InvalidDirectiveErrorCode    ErrorCode = "invalid-directive"
+	SyntaxErrorCode              ErrorCode = "syntax"
+	UnknownInstructionErrorCode  ErrorCode = "unknown-instruction"
+	InvalidArgumentsErrorCode    ErrorCode = "invalid-arguments"
+	UnterminatedHeredocErrorCode ErrorCode = "unterminated-heredoc"
+)
+
+// ParseError describes a failure to parse a Dockerfile.
+type ParseError struct {
+	// Line and Column are the 1-indexed position of the error in the Dockerfile, or 0 if not applicable.
+	Line   int
+	Column int
+	// Instruction is the type of the instruction which failed to parse, if known.
+	Instruction statement.Type
+	// Snippet is the offending text.
+	Snippet string
+	Code    ErrorCode
+	// Err is the underlying error.
+	Err error
+}
+
+func newParseError(code ErrorCode, snippet string, format string, a ...interface{}) *ParseError {
+	return &ParseError{
+		Code:    code,
+		Snippet: snippet,
+		Err:     fmt.Errorf(format, a...),
+	}
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("failed parsing dockerfile: %v", e.Err)
+	}
+	return fmt.Sprintf("failed parsing statement on line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is the list of failures encountered while parsing a Dockerfile with error recovery, in order of appearance.
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// As allows the first failure to be retrieved using `errors.As`.
+func (l ErrorList) As(target interface{}) bool {
+	if len(l) == 0 {
+		return false
+	}
+	return errors.As(l[0], target)
+}
@@ -1,7 +1,96 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// EXPOSE is an instruction of the form:
+// `EXPOSE <port>[/<protocol>] [<port>[/<protocol>]...]`
+// where each port may also be an inclusive range, e.g. `8000-8010/udp`.
+// See: https://docs.docker.com/engine/reference/builder/#expose
 func scanEXPOSE(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.EXPOSE {
+		return nil, lines, fmt.Errorf("not an EXPOSE statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	specs := shell.NewLexer(escapeCharacter).Fields(rawArgs)
+	if len(specs) == 0 {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, "", "EXPOSE requires at least one port")
+	}
+	inst := &statement.ExposeInstruction{Lines: statementLines}
+	for _, raw := range specs {
+		spec, err := ParsePortSpec(raw)
+		if err != nil {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, raw, "%v", err)
+		}
+		inst.Ports = append(inst.Ports, spec)
+	}
+	return inst, remainingLines, nil
+}
+
+/*
+ParsePortSpec parses a single argument of an EXPOSE instruction, which is a
+port or an inclusive range of ports, optionally followed by a protocol:
+
+	80
+	8000-8010/udp
+
+The protocol is one of `tcp`, `udp` or `sctp`, and defaults to `tcp`.
+Specs containing variable references are returned unparsed, with only Raw set.
+*/
+func ParsePortSpec(raw string) (statement.PortSpec, error) {
+	spec := statement.PortSpec{Raw: raw}
+	if hasVariableReference(raw) {
+		return spec, nil
+	}
+
+	ports, proto := raw, string(statement.TCP)
+	if i := strings.LastIndex(raw, "/"); i != -1 {
+		ports, proto = raw[:i], strings.ToLower(raw[i+1:])
+	}
+	switch statement.Protocol(proto) {
+	case statement.TCP, statement.UDP, statement.SCTP:
+		spec.Protocol = statement.Protocol(proto)
+	default:
+		return spec, fmt.Errorf("invalid protocol %q, must be one of [tcp, udp, sctp]: %q", proto, raw)
+	}
+	if strings.Contains(ports, ":") {
+		return spec, fmt.Errorf("EXPOSE does not support publishing to a host port, only the container port may be specified: %q", raw)
+	}
+
+	start, end := ports, ports
+	if i := strings.Index(ports, "-"); i != -1 {
+		start, end = ports[:i], ports[i+1:]
+	}
+	var err error
+	if spec.Start, err = parsePortNumber(start); err != nil {
+		return spec, fmt.Errorf("%v: %q", err, raw)
+	}
+	if spec.End, err = parsePortNumber(end); err != nil {
+		return spec, fmt.Errorf("%v: %q", err, raw)
+	}
+	if spec.End < spec.Start {
+		return spec, fmt.Errorf("invalid port range, %d is greater than %d: %q", spec.Start, spec.End, raw)
+	}
+	return spec, nil
+}
+
+func parsePortNumber(s string) (uint16, error) {
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid port %q, must be a number between 1 and 65535", s)
+	}
+	return uint16(n), nil
 }
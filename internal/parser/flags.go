@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+const flagPrefix = "--"
+
+/*
+scanFlags consumes the leading `--<name>[=<value>]` flags of the given instruction arguments, in order of appearance.
+Flags may be repeated, and values may be quoted, e.g.:
+
+	--mount=type=cache,target=/root/.cache --mount=type=secret,id=token --chown="app user" --link
+
+Flags without a value are boolean, see parseBoolFlag. A bare `--` ends the flags.
+*/
+func scanFlags(rawArgs string, escapeCharacter rune) (flags []statement.Flag, remainingArgs string, err error) {
+	lexer := shell.NewLexer(escapeCharacter)
+	remainingArgs = strings.TrimSpace(rawArgs)
+	for strings.HasPrefix(remainingArgs, flagPrefix) {
+		word, rest, _, err := lexer.Next(remainingArgs)
+		if err != nil {
+			return nil, rawArgs, newParseError(InvalidArgumentsErrorCode, remainingArgs, "invalid flag: %v", err)
+		}
+		remainingArgs = strings.TrimSpace(rest)
+		if word.Raw == flagPrefix {
+			break
+		}
+		split := strings.SplitN(strings.TrimPrefix(word.Value, flagPrefix), "=", 2)
+		if split[0] == "" {
+			return nil, rawArgs, newParseError(InvalidArgumentsErrorCode, word.Raw, "flag %q has no name", word.Raw)
+		}
+		if len(split) == 2 {
+			flags = append(flags, statement.Flag{Name: split[0], Value: split[1]})
+		} else {
+			flags = append(flags, statement.Flag{Name: split[0], IsBool: true})
+		}
+	}
+	return flags, remainingArgs, nil
+}
+
+// scanNoFlags consumes the leading flags of the arguments of an instruction which does not accept any flags,
+// failing if there were any.
+func scanNoFlags(st statement.Type, rawArgs string, escapeCharacter rune) (remainingArgs string, err error) {
+	flags, remainingArgs, err := scanFlags(rawArgs, escapeCharacter)
+	if err != nil {
+		return rawArgs, err
+	}
+	if len(flags) > 0 {
+		return rawArgs, unknownFlagError(st, flags[0])
+	}
+	return remainingArgs, nil
+}
+
+// parseBoolFlag interprets a boolean flag, which may be given with an explicit value, e.g. `--link=false`.
+func parseBoolFlag(st statement.Type, f statement.Flag) (bool, error) {
+	if f.IsBool {
+		return true, nil
+	}
+	switch strings.ToLower(f.Value) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, newParseError(InvalidArgumentsErrorCode, f.String(), "%s flag %q expects a boolean value, got %q", st, f.Name, f.Value)
+}
+
+// requireFlagValue ensures that the given flag was passed a value.
+func requireFlagValue(st statement.Type, f statement.Flag) (string, error) {
+	if f.IsBool {
+		return "", newParseError(InvalidArgumentsErrorCode, f.String(), "%s flag %q requires a value", st, f.Name)
+	}
+	return f.Value, nil
+}
+
+func unknownFlagError(st statement.Type, f statement.Flag) error {
+	return newParseError(InvalidArgumentsErrorCode, f.String(), "unknown %s flag: %q", st, f.Name)
+}
+
+// flagOrder is the names of the given flags in the order they were written,
+// failing if any flag other than the repeatable ones was passed more than once, as BuildKit does.
+func flagOrder(st statement.Type, flags []statement.Flag, repeatable ...string) ([]string, error) {
+	isRepeatable := make(map[string]bool, len(repeatable))
+	for _, name := range repeatable {
+		isRepeatable[name] = true
+	}
+	var order []string
+	seen := map[string]bool{}
+	for _, f := range flags {
+		if seen[f.Name] && !isRepeatable[f.Name] {
+			return nil, newParseError(InvalidArgumentsErrorCode, f.String(), "%s flag %q may only be specified once", st, f.Name)
+		}
+		seen[f.Name] = true
+		order = append(order, f.Name)
+	}
+	return order, nil
+}
@@ -2,23 +2,15 @@ package parser
 
 import (
 	"fmt"
-	"regexp"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
-var fromInstructionMatcher = regexp.MustCompile(
-	"(?i)" + // case insensitive
-		reStartOfLine +
-		reOptionalWhitespace +
-		"FROM" +
-		reWhitespace +
-		"(?:--platform=(" + reNotWhitespace + ")" + reWhitespace + ")?" + // platform, group 1
-		"(" + reNotWhitespace + ")" + // image, group 2
-		"(?:AS" + reWhitespace + "(" + reNotWhitespace + "))?" + // alias, group 3
-		reDontCare +
-		reEndOfLine)
-
+// FROM is an instruction of the form:
+// `FROM [--platform=<platform>] <image> [AS <name>]`
+// See: https://docs.docker.com/engine/reference/builder/#from
 func scanFROM(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
 	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
 	if err != nil {
@@ -28,15 +20,34 @@ func scanFROM(lines []string, escapeCharacter rune) (stmt statement.Statement, r
 		return nil, lines, fmt.Errorf("not a FROM statement: %q", statementLines[0])
 	}
 
-	stmtLine := string(statement.FROM) + " " + rawArgs
-	reMatches := fromInstructionMatcher.FindStringSubmatch(stmtLine)
-	if len(reMatches) != 4 {
-		return nil, lines, fmt.Errorf("syntax error, could not parse FROM statement: %q", stmtLine)
+	inst := &statement.FromInstruction{}
+	flags, rawArgs, err := scanFlags(rawArgs, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	for _, f := range flags {
+		switch f.Name {
+		case "platform":
+			inst.Platform, err = requireFlagValue(st, f)
+		default:
+			err = unknownFlagError(st, f)
+		}
+		if err != nil {
+			return nil, lines, err
+		}
 	}
-	inst := &statement.FromInstruction{
-		Platform: reMatches[1],
-		Image:    reMatches[2],
-		Alias:    reMatches[3],
+	if _, err = flagOrder(st, flags); err != nil {
+		return nil, lines, err
+	}
+
+	args := shell.NewLexer(escapeCharacter).Fields(rawArgs)
+	switch {
+	case len(args) == 1:
+		inst.Image = args[0]
+	case len(args) == 3 && strings.EqualFold(args[1], "AS"):
+		inst.Image, inst.Alias = args[0], args[2]
+	default:
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "syntax error, FROM requires either one argument, or three of the form `<image> AS <name>`: %q", rawArgs)
 	}
 	return inst, remainingLines, nil
 }
@@ -1,7 +1,114 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+/*
+HEALTHCHECK instructions configure how the container's health is checked. There are 2 forms:
+
+	a) `HEALTHCHECK [--<option>...] CMD <command>`, where the command is in exec or shell form, as for CMD
+	b) `HEALTHCHECK NONE`, which disables any health check inherited from the base image
+
+The options are `--interval`, `--timeout`, `--start-period` and `--start-interval`,
+which are durations such as `30s` or `1m30s`, and `--retries`, which is a count.
+
+See: https://docs.docker.com/engine/reference/builder/#healthcheck
+*/
 func scanHEALTHCHECK(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.HEALTHCHECK {
+		return nil, lines, fmt.Errorf("not a HEALTHCHECK statement: %q", statementLines[0])
+	}
+	inst := &statement.HealthcheckInstruction{Lines: statementLines}
+
+	flags, rawArgs, err := scanFlags(rawArgs, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	for _, f := range flags {
+		if !healthcheckFlags[f.Name] {
+			return nil, lines, unknownFlagError(st, f)
+		}
+		var val string
+		if val, err = requireFlagValue(st, f); err != nil {
+			return nil, lines, err
+		}
+		switch f.Name {
+		case "interval":
+			inst.Interval, err = parseHealthcheckDuration(f)
+		case "timeout":
+			inst.Timeout, err = parseHealthcheckDuration(f)
+		case "start-period":
+			inst.StartPeriod, err = parseHealthcheckDuration(f)
+		case "start-interval":
+			inst.StartInterval, err = parseHealthcheckDuration(f)
+		case "retries":
+			if inst.Retries, err = strconv.Atoi(val); err != nil || inst.Retries < 0 {
+				err = newParseError(InvalidArgumentsErrorCode, f.String(), "HEALTHCHECK retries must be a non-negative integer, got %q", val)
+			}
+		}
+		if err != nil {
+			return nil, lines, err
+		}
+	}
+	if inst.FlagOrder, err = flagOrder(st, flags); err != nil {
+		return nil, lines, err
+	}
+
+	keyword := rawArgs
+	if i := strings.IndexFunc(rawArgs, unicode.IsSpace); i != -1 {
+		keyword = rawArgs[:i]
+	}
+	switch strings.ToUpper(keyword) {
+	case "NONE":
+		if keyword != rawArgs {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "HEALTHCHECK NONE takes no arguments: %q", rawArgs)
+		}
+		inst.None = true
+	case string(statement.CMD):
+		cmdArgs := strings.TrimSpace(rawArgs[len(keyword):])
+		if cmdArgs == "" {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "HEALTHCHECK CMD requires a command")
+		}
+		cmd, _, err := scanCMD([]string{string(statement.CMD) + " " + cmdArgs}, escapeCharacter)
+		if err != nil {
+			return nil, lines, err
+		}
+		inst.Cmd = cmd.(*statement.GenericInstruction)
+		inst.Cmd.Lines = nil
+	default:
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "HEALTHCHECK must be followed by NONE or CMD: %q", rawArgs)
+	}
+	return inst, remainingLines, nil
+}
+
+// healthcheckFlags are the flags accepted by HEALTHCHECK, all of which require a value.
+var healthcheckFlags = map[string]bool{
+	"interval":       true,
+	"timeout":        true,
+	"start-period":   true,
+	"start-interval": true,
+	"retries":        true,
+}
+
+// parseHealthcheckDuration parses the value of a duration flag, which must be zero or at least one millisecond.
+func parseHealthcheckDuration(f statement.Flag) (time.Duration, error) {
+	d, err := time.ParseDuration(f.Value)
+	if err != nil {
+		return 0, newParseError(InvalidArgumentsErrorCode, f.String(), "HEALTHCHECK %s must be a duration such as `30s`, got %q", f.Name, f.Value)
+	}
+	if d != 0 && d < time.Millisecond {
+		return 0, newParseError(InvalidArgumentsErrorCode, f.String(), "HEALTHCHECK %s cannot be less than 1ms, got %q", f.Name, f.Value)
+	}
+	return d, nil
 }
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+var heredocMatcher = regexp.MustCompile(
+	reStartOfLine +
+		"[0-9]*<<(-?)" + // optional file descriptor, operator, optional chomp, group 1
+		"(?:" +
+		`"([^"]+)"` + // double-quoted name, group 2
+		"|" + // or
+		`'([^']+)'` + // single-quoted name, group 3
+		"|" + // or
+		`([^<"'[:space:]]+)` + // unquoted name, group 4
+		")" +
+		reEndOfLine)
+
+/*
+Heredocs may be passed to RUN, COPY and ADD instructions in their shell forms:
+
+	RUN <<EOF
+	apt-get update
+	apt-get install -y curl
+	EOF
+
+Multiple heredocs may be referenced by a single instruction, in which case
+their bodies follow the instruction one after another:
+
+	COPY <<FILE1 <<FILE2 /dest/
+	contents of file 1
+	FILE1
+	contents of file 2
+	FILE2
+
+The `<<-` form strips leading tabs from the body and the terminating line.
+Quoting the name, e.g. `<<"EOF"`, disables variable expansion in the body.
+
+See: https://docs.docker.com/engine/reference/builder/#here-documents
+*/
+func scanHeredocs(args []string, lines []string) (heredocs []statement.Heredoc, remainingLines []string, err error) {
+	remainingLines = lines
+	for _, arg := range args {
+		reMatches := heredocMatcher.FindStringSubmatch(arg)
+		if len(reMatches) == 0 {
+			continue
+		}
+		heredoc := statement.Heredoc{
+			Name:      reMatches[2] + reMatches[3] + reMatches[4],
+			Delimiter: arg,
+			Expand:    reMatches[4] != "",
+			Chomp:     reMatches[1] == "-",
+		}
+		body := strings.Builder{}
+		terminated := false
+		for !terminated && len(remainingLines) > 0 {
+			line := remainingLines[0]
+			remainingLines = remainingLines[1:]
+			if heredoc.Chomp {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == heredoc.Name {
+				terminated = true
+				continue
+			}
+			body.WriteString(line + "\n")
+		}
+		if !terminated {
+			return nil, lines, newParseError(UnterminatedHeredocErrorCode, arg, "heredoc %q was not terminated by %q", arg, heredoc.Name)
+		}
+		heredoc.Body = body.String()
+		heredocs = append(heredocs, heredoc)
+	}
+	return heredocs, remainingLines, nil
+}
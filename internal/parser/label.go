@@ -1,7 +1,87 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+var labelArgMatcher = newEscapeAwareMatcher(func(p wordPatterns) string {
+	// The key is a shell word which does not contain an unquoted `=`.
+	key := ("(?:" +
+		p.singleQuotedVal +
+		"|" + // or
+		p.doubleQuotedVal +
+		"|" + // or
+		p.escapedCharacter +
+		"|" + // or
+		strings.Replace(p.plainCharacter, "[^", "[^=", 1) +
+		")+")
+	return reStartOfLine +
+		"(" + key + ")" + // key
+		"=" +
+		"(" + p.word + "|" + ")" + // value, possibly empty
+		"(?:" + reWhitespace + "|" + reEndOfLine + ")" // whitespace or EOL
+})
+
+/*
+LABEL instructions add metadata to the image as key-value pairs.
+Keys and values may be quoted or escaped following the same rules as ENV:
+
+	`LABEL "com.example.vendor"="ACME Incorporated" version=1.0 description="This text illustrates \
+	that label-values can span multiple lines."`
+
+Like ENV, the legacy `LABEL <key> <value>` form defines exactly one label.
+
+See: https://docs.docker.com/engine/reference/builder/#label
+*/
 func scanLABEL(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.LABEL {
+		return nil, lines, fmt.Errorf("not a LABEL statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	rawArgs = strings.TrimSpace(rawArgs)
+	if rawArgs == "" {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, "", "LABEL requires arguments")
+	}
+
+	inst := &statement.LabelInstruction{Lines: statementLines}
+	if !labelArgMatcher.with(escapeCharacter).MatchString(rawArgs) {
+		// This is the legacy form
+		rawKey := strings.Fields(rawArgs)[0]
+		rawVal := strings.TrimSpace(rawArgs[len(rawKey):])
+		if rawVal == "" {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "LABEL %q is missing a value", rawKey)
+		}
+		inst.Labels = []statement.Label{newLabel(rawKey, rawVal, escapeCharacter)}
+		return inst, remainingLines, nil
+	}
+
+	unparsed := rawArgs
+	for unparsed != "" {
+		argMatch := labelArgMatcher.with(escapeCharacter).FindStringSubmatch(unparsed)
+		if len(argMatch) == 0 {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, unparsed, "could not parse next `key=value`: %q", unparsed)
+		}
+		inst.Labels = append(inst.Labels, newLabel(argMatch[1], argMatch[2], escapeCharacter))
+		unparsed = unparsed[len(argMatch[0]):]
+	}
+	return inst, remainingLines, nil
+}
+
+func newLabel(rawKey, rawVal string, escapeCharacter rune) statement.Label {
+	return statement.Label{
+		Key:      statement.Unquote(rawKey, escapeCharacter),
+		Value:    statement.Unquote(rawVal, escapeCharacter),
+		RawKey:   rawKey,
+		RawValue: rawVal,
+	}
 }
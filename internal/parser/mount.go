@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+var octalModeMatcher = regexp.MustCompile(reStartOfLine + "[0-7]+" + reEndOfLine)
+
+/*
+ParseMount parses the value of a RUN instruction's `--mount` flag, which is a
+comma-separated list of `<key>[=<value>]` options, e.g.:
+
+	type=cache,target=/root/.cache/go-build,sharing=locked
+
+Mounts default to the `bind` type. Values may be quoted as in CSV.
+
+See: https://docs.docker.com/engine/reference/builder/#run---mount
+*/
+func ParseMount(val string) (statement.Mount, error) {
+	fields, err := csv.NewReader(strings.NewReader(val)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse mount options %q: %w", val, err)
+	}
+
+	opts := make(map[string]string, len(fields))
+	var keys, order []string
+	mountType := statement.BindMountType
+	for _, field := range fields {
+		key, value := field, ""
+		if split := strings.SplitN(field, "=", 2); len(split) == 2 {
+			key, value = split[0], split[1]
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "type" {
+			mountType = statement.MountType(strings.ToLower(value))
+			order = append(order, key)
+			continue
+		}
+		if alias, ok := mountOptionAliases[key]; ok {
+			key = alias
+		}
+		if _, dup := opts[key]; dup {
+			return nil, fmt.Errorf("mount option %q set multiple times: %q", key, val)
+		}
+		opts[key] = value
+		keys = append(keys, key)
+		order = append(order, key)
+	}
+
+	allowed, known := mountOptionsFor[mountType]
+	if !known {
+		return nil, fmt.Errorf("unknown mount type %q: %q", mountType, val)
+	}
+	for _, key := range keys {
+		if !allowed[key] {
+			return nil, fmt.Errorf("unknown option %q for mount type %q: %q", key, mountType, val)
+		}
+	}
+	if mode, ok := opts["mode"]; ok && !hasVariableReference(mode) && !octalModeMatcher.MatchString(mode) {
+		return nil, fmt.Errorf("mount mode must be octal, got %q", mode)
+	}
+
+	var boolErr error
+	boolOpt := func(key string) bool {
+		value, ok := opts[key]
+		if !ok {
+			return false
+		}
+		switch strings.ToLower(value) {
+		case "", "true":
+			return true
+		case "false":
+			return false
+		}
+		boolErr = fmt.Errorf("mount option %q expects a boolean value, got %q", key, value)
+		return false
+	}
+
+	var m statement.Mount
+	switch mountType {
+	case statement.BindMountType:
+		readWrite := boolOpt("rw")
+		if _, ok := opts["ro"]; ok {
+			// Bind mounts are read-only by default, `ro=false` makes them read-write.
+			readWrite = !boolOpt("ro")
+		}
+		m = &statement.BindMount{
+			Target:      opts["target"],
+			Source:      opts["source"],
+			From:        opts["from"],
+			ReadWrite:   readWrite,
+			OptionOrder: order,
+		}
+	case statement.CacheMountType:
+		cache := &statement.CacheMount{
+			ID:          opts["id"],
+			Target:      opts["target"],
+			ReadOnly:    boolOpt("ro"),
+			Sharing:     opts["sharing"],
+			From:        opts["from"],
+			Source:      opts["source"],
+			Mode:        opts["mode"],
+			UID:         opts["uid"],
+			GID:         opts["gid"],
+			OptionOrder: order,
+		}
+		switch cache.Sharing {
+		case "", "shared", "private", "locked":
+		default:
+			return nil, fmt.Errorf("cache mount sharing must be one of [shared, private, locked], got %q", cache.Sharing)
+		}
+		m = cache
+	case statement.TmpfsMountType:
+		m = &statement.TmpfsMount{
+			Target:      opts["target"],
+			Size:        opts["size"],
+			OptionOrder: order,
+		}
+	case statement.SecretMountType:
+		m = &statement.SecretMount{
+			ID:          opts["id"],
+			Target:      opts["target"],
+			Source:      opts["source"],
+			Env:         opts["env"],
+			Required:    boolOpt("required"),
+			Mode:        opts["mode"],
+			UID:         opts["uid"],
+			GID:         opts["gid"],
+			OptionOrder: order,
+		}
+	case statement.SSHMountType:
+		m = &statement.SSHMount{
+			ID:          opts["id"],
+			Target:      opts["target"],
+			Required:    boolOpt("required"),
+			Mode:        opts["mode"],
+			UID:         opts["uid"],
+			GID:         opts["gid"],
+			OptionOrder: order,
+		}
+	}
+	if boolErr != nil {
+		return nil, boolErr
+	}
+	if target := opts["target"]; target == "" && (mountType == statement.BindMountType || mountType == statement.CacheMountType || mountType == statement.TmpfsMountType) {
+		return nil, fmt.Errorf("%s mount requires a target: %q", mountType, val)
+	}
+	return m, nil
+}
+
+var mountOptionAliases = map[string]string{
+	"dst":         "target",
+	"destination": "target",
+	"src":         "source",
+	"readwrite":   "rw",
+	"readonly":    "ro",
+}
+
+var mountOptionsFor = map[statement.MountType]map[string]bool{
+	statement.BindMountType: {
+		"target": true, "source": true, "from": true, "rw": true, "ro": true,
+	},
+	statement.CacheMountType: {
+		"id": true, "target": true, "ro": true, "sharing": true, "from": true, "source": true, "mode": true, "uid": true, "gid": true,
+	},
+	statement.TmpfsMountType: {
+		"target": true, "size": true,
+	},
+	statement.SecretMountType: {
+		"id": true, "target": true, "source": true, "env": true, "required": true, "mode": true, "uid": true, "gid": true,
+	},
+	statement.SSHMountType: {
+		"id": true, "target": true, "required": true, "mode": true, "uid": true, "gid": true,
+	},
+}
+
+func hasVariableReference(s string) bool {
+	return strings.Contains(s, "$")
+}
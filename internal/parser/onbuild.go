@@ -1,7 +1,58 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// forbiddenOnBuildTriggers are the instructions which may not be used as ONBUILD triggers.
+var forbiddenOnBuildTriggers = map[statement.Type]bool{
+	statement.ONBUILD:    true,
+	statement.FROM:       true,
+	statement.MAINTAINER: true,
+}
+
+func init() {
+	// Registered here, rather than in the statementScannerFor literal, as scanning the trigger refers back to it.
+	statementScannerFor[statement.ONBUILD] = scanONBUILD
+}
+
+// ONBUILD is an instruction of the form:
+// `ONBUILD <instruction>`
+// where the trigger instruction is any instruction other than ONBUILD, FROM or MAINTAINER.
+// See: https://docs.docker.com/engine/reference/builder/#onbuild
 func scanONBUILD(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.ONBUILD {
+		return nil, lines, fmt.Errorf("not an ONBUILD statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	rawArgs = strings.TrimSpace(rawArgs)
+	if rawArgs == "" {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, "", "ONBUILD requires a trigger instruction")
+	}
+	trigger := statement.Type(strings.ToUpper(strings.Fields(rawArgs)[0]))
+	if forbiddenOnBuildTriggers[trigger] {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "%s is not allowed as an ONBUILD trigger instruction", trigger)
+	}
+	triggerStmt, _, err := scanInstruction([]string{rawArgs}, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	inst, ok := triggerStmt.(statement.Instruction)
+	if !ok {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "ONBUILD trigger must be an instruction: %q", rawArgs)
+	}
+	return &statement.OnBuildInstruction{
+		Trigger: inst,
+		Lines:   statementLines,
+	}, remainingLines, nil
 }
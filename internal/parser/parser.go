@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
@@ -14,8 +16,17 @@ const (
 	WindowsEscapeCharacter = '`'
 
 	EscapeParserDirectiveKey = "escape"
+	SyntaxParserDirectiveKey = "syntax"
+	CheckParserDirectiveKey  = "check"
 )
 
+// Directive is a parser directive, e.g. `# syntax=docker/dockerfile:1`.
+type Directive struct {
+	// Key is the lower-cased name of the directive.
+	Key   string
+	Value string
+}
+
 type statementScanFn func(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error)
 
 // var canHaveContinuation = map[statement.Type]bool{
@@ -56,7 +67,6 @@ var statementScannerFor = map[statement.Type]statementScanFn{
 	statement.HEALTHCHECK: scanHEALTHCHECK,
 	statement.LABEL:       scanLABEL,
 	statement.MAINTAINER:  scanMAINTAINER,
-	statement.ONBUILD:     scanONBUILD,
 	statement.RUN:         scanRUN,
 	statement.SHELL:       scanSHELL,
 	statement.STOPSIGNAL:  scanSTOPSIGNAL,
@@ -65,50 +75,77 @@ var statementScannerFor = map[statement.Type]statementScanFn{
 	statement.WORKDIR:     scanWORKDIR,
 }
 
-type Sequential struct {
-	escapeCharacter rune
-	statements      []statement.Statement
-	directives      map[string]string
-}
-
-func (p *Sequential) Parse(lines []string) ([]statement.Statement, rune, error) {
-	totalLines := len(lines)
-	if totalLines == 0 {
-		return nil, 0, errors.New("dockerfile was empty")
+// scanBadStatement consumes the region of lines which failed to parse as a statement.
+// The region spans the lines of the failed instruction, plus any following lines up until the next blank line,
+// comment, or line beginning with a known instruction, at which point parsing can resume.
+func scanBadStatement(lines []string, escapeCharacter rune, err *ParseError) (stmt statement.Statement, remainingLines []string) {
+	remainingLines = lines[1:]
+	if _, _, _, rest, scanErr := scanInstructionLines(lines, escapeCharacter); scanErr == nil {
+		remainingLines = rest
+	}
+	for len(remainingLines) > 0 {
+		line := remainingLines[0]
+		if blankLineMatcher.MatchString(line) || commentLineMatcher.MatchString(line) {
+			break
+		}
+		if reMatches := instructionLineMatcher.FindStringSubmatch(strings.TrimSpace(line)); len(reMatches) == 2 && statement.Known[statement.Type(strings.ToUpper(reMatches[1]))] {
+			break
+		}
+		remainingLines = remainingLines[1:]
 	}
-	p.escapeCharacter = DefaultExcapeCharacter
-	p.statements = nil
-	p.directives = map[string]string{}
+	return &statement.BadStatement{
+		Lines: lines[:len(lines)-len(remainingLines)],
+		Err:   err,
+	}, remainingLines
+}
 
-	directives, remainingLines, err := scanParserDirectives(lines)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read parser directives: %w", err)
+// locateError converts the error encountered while scanning the statement at the start of the given lines into a
+// ParseError, filling in any details about its location which are not already known.
+func locateError(err error, lines []string, firstLineNum int, escapeCharacter rune) *ParseError {
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		pe = &ParseError{Code: SyntaxErrorCode, Err: err}
 	}
-	p.directives = directives
-	if val, exists := p.directives[EscapeParserDirectiveKey]; exists {
-		vRunes := []rune(val)
-		if len(vRunes) > 1 || !(vRunes[0] == DefaultExcapeCharacter || vRunes[0] == WindowsEscapeCharacter) {
-			return nil, 0, fmt.Errorf("escape token must be one of [%q, %q], got %q", DefaultExcapeCharacter, WindowsEscapeCharacter, val)
+	firstLine := lines[0]
+	if pe.Instruction == "" {
+		// The first word, since an instruction which is missing its arguments does not match instructionLineMatcher.
+		if words := strings.Fields(firstLine); len(words) > 0 {
+			if st := statement.Type(strings.ToUpper(words[0])); statement.Known[st] && st != statement.CommentType {
+				pe.Instruction = st
+			}
 		}
-		p.escapeCharacter = vRunes[0]
 	}
-
-	for len(remainingLines) > 0 {
-		if blankLineMatcher.MatchString(remainingLines[0]) {
-			// skip blank lines
-			remainingLines = remainingLines[1:]
-			continue
+	if pe.Snippet == "" {
+		pe.Snippet = strings.TrimSpace(firstLine)
+	}
+	if pe.Line == 0 {
+		// Look for the snippet within the arguments first, since it may also appear in the instruction name.
+		if _, args, _, _, err := mapInstructionLines(lines, escapeCharacter); err == nil {
+			if offset := strings.Index(args.Text, pe.Snippet); offset != -1 {
+				if pos := args.Position(offset); pos.Line != 0 {
+					pe.Line, pe.Column = firstLineNum+pos.Line-1, pos.Column
+					return pe
+				}
+			}
 		}
-		var stmt statement.Statement
-		var err error
-		stmt, remainingLines, err = scanStatement(remainingLines, p.escapeCharacter)
-		if err != nil {
-			lineNum := totalLines - len(remainingLines) + 1
-			return nil, 0, fmt.Errorf("failed parsing statement on line %d: %w", lineNum, err)
+		pe.Line = firstLineNum
+		pe.Column = len(firstLine) - len(strings.TrimLeftFunc(firstLine, unicode.IsSpace)) + 1
+		// Find the physical line containing the snippet, which may be a continuation of the first.
+		for i, line := range lines {
+			if col := strings.Index(line, pe.Snippet); col != -1 {
+				pe.Line, pe.Column = firstLineNum+i, col+1
+				break
+			}
+			trimmed := strings.TrimSpace(line)
+			if i > 0 && (trimmed == "" || commentLineMatcher.MatchString(trimmed)) {
+				continue
+			}
+			if !hasContinuation(trimmed, escapeCharacter) {
+				break
+			}
 		}
-		p.statements = append(p.statements, stmt)
 	}
-	return p.statements, p.escapeCharacter, nil
+	return pe
 }
 
 const (
@@ -150,7 +187,7 @@ var (
 			reOptionalWhitespace +
 			"=" +
 			reOptionalWhitespace +
-			"(" + reNotWhitespaceOrEquals + ")" + // value
+			"(" + reNotWhitespace + ")" + // value
 			reOptionalWhitespace +
 			reEndOfLine)
 )
@@ -160,8 +197,8 @@ var (
 // As soon as the parser encounters a blank line, an instruction,
 // or a comment that does not match this form, it will treat all remaining comments as ordinary.
 // See: https://docs.docker.com/engine/reference/builder/#parser-directives
-func scanParserDirectives(lines []string) (directives map[string]string, remainingLines []string, err error) {
-	directives = map[string]string{}
+func scanParserDirectives(lines []string) (directives []Directive, remainingLines []string, err error) {
+	seen := map[string]bool{}
 	remainingLines = lines
 	for len(remainingLines) > 0 {
 		matches := parserDirectiveMatcher.FindStringSubmatch(remainingLines[0])
@@ -170,15 +207,71 @@ func scanParserDirectives(lines []string) (directives map[string]string, remaini
 		}
 		k, v := strings.TrimSpace(matches[1]), strings.TrimSpace(matches[2])
 		k = strings.ToLower(k)
-		if _, alreadySet := directives[k]; alreadySet {
-			return nil, lines, fmt.Errorf("directive %q set multiple times", k)
+		if seen[k] {
+			return nil, lines, directiveError(remainingLines[0], len(lines)-len(remainingLines)+1, "directive %q set multiple times", k)
 		}
-		directives[k] = v
+		if _, err := ParseEscapeCharacter(v); k == EscapeParserDirectiveKey && err != nil {
+			return nil, lines, directiveError(remainingLines[0], len(lines)-len(remainingLines)+1, "%v", err)
+		}
+		seen[k] = true
+		directives = append(directives, Directive{Key: k, Value: v})
 		remainingLines = remainingLines[1:]
 	}
 	return directives, remainingLines, nil
 }
 
+// ParseEscapeCharacter parses the value of the `escape` parser directive, which must be either
+// DefaultExcapeCharacter or WindowsEscapeCharacter.
+func ParseEscapeCharacter(v string) (rune, error) {
+	if vRunes := []rune(v); len(vRunes) == 1 && (vRunes[0] == DefaultExcapeCharacter || vRunes[0] == WindowsEscapeCharacter) {
+		return vRunes[0], nil
+	}
+	return 0, fmt.Errorf("escape token must be one of [%q, %q], got %q", DefaultExcapeCharacter, WindowsEscapeCharacter, v)
+}
+
+func directiveError(line string, lineNum int, format string, a ...interface{}) *ParseError {
+	pe := newParseError(InvalidDirectiveErrorCode, strings.TrimSpace(line), "invalid parser directive: "+format, a...)
+	return locateError(pe, []string{line}, lineNum, DefaultExcapeCharacter)
+}
+
+type locatable interface {
+	SetLocation(statement.Range)
+	SetArgumentsMap(statement.SourceMap)
+}
+
+// mapArguments maps the arguments of the instruction spanning the given physical lines, the first of which is on line `firstLineNum`,
+// back to the original Dockerfile.
+func mapArguments(statementLines []string, firstLineNum int, escapeCharacter rune) statement.SourceMap {
+	_, args, _, _, err := mapInstructionLines(statementLines, escapeCharacter)
+	if err != nil {
+		return statement.SourceMap{}
+	}
+	for i := range args.Segments {
+		args.Segments[i].Start.Line += firstLineNum - 1
+	}
+	return args
+}
+
+// locate computes the span of the given physical lines of a statement, the first of which is on line `firstLineNum`.
+// Trailing blank lines, e.g. those following a continuation at the end of the file, are not part of the span.
+func locate(statementLines []string, firstLineNum int) statement.Range {
+	last := len(statementLines) - 1
+	for last > 0 && blankLineMatcher.MatchString(statementLines[last]) {
+		last--
+	}
+	first := statementLines[0]
+	return statement.Range{
+		Start: statement.Position{
+			Line:   firstLineNum,
+			Column: len(first) - len(strings.TrimLeftFunc(first, unicode.IsSpace)) + 1,
+		},
+		End: statement.Position{
+			Line:   firstLineNum + last,
+			Column: len(strings.TrimRightFunc(statementLines[last], unicode.IsSpace)) + 1,
+		},
+	}
+}
+
 func hasContinuation(line string, escapeCharacter rune) bool {
 	// TODO: support long stretches of terminal escapes? This is consistent with buildkit's impl
 	// https://github.com/moby/buildkit/blob/1031116f12ec6f80c11782c93a48891f848168b9/frontend/dockerfile/parser/parser.go#L164
@@ -196,11 +289,13 @@ func scanInstruction(lines []string, escapeCharacter rune) (stmt statement.State
 	currentLine := strings.TrimSpace(lines[0])
 	reMatches := instructionLineMatcher.FindStringSubmatch(currentLine)
 	if len(reMatches) != 2 {
-		return nil, lines, fmt.Errorf("syntax error: %q", currentLine)
+		return nil, lines, newParseError(SyntaxErrorCode, currentLine, "syntax error: %q", currentLine)
 	}
 	instruction := statement.Type(strings.ToUpper(reMatches[1]))
 	if !statement.Known[instruction] {
-		return nil, lines, fmt.Errorf("unknown instruction: %q", instruction)
+		pe := newParseError(UnknownInstructionErrorCode, reMatches[1], "unknown instruction: %q", instruction)
+		pe.Instruction = instruction
+		return nil, lines, pe
 	}
 
 	if scanInstruction, exists := statementScannerFor[instruction]; exists {
@@ -217,8 +312,11 @@ func scanGenericInstruction(lines []string, escapeCharacter rune) (stmt statemen
 	}
 	inst.InstructionType = st
 	inst.Lines = statementLines
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
 	inst.Args = statement.Arguments{
-		List:     strings.Fields(rawArgs),
+		List:     shell.NewLexer(escapeCharacter).Fields(rawArgs),
 		Execable: false,
 	}
 
@@ -226,18 +324,28 @@ func scanGenericInstruction(lines []string, escapeCharacter rune) (stmt statemen
 }
 
 func scanInstructionLines(lines []string, escapeCharacter rune) (st statement.Type, rawArgs string, statementLines, remainingLines []string, err error) {
+	st, args, statementLines, remainingLines, err := mapInstructionLines(lines, escapeCharacter)
+	return st, args.Text, statementLines, remainingLines, err
+}
+
+// mapInstructionLines is scanInstructionLines, additionally mapping the raw arguments back to the given lines.
+// Segment positions are relative to the given lines, i.e. line 1 is the first of them.
+func mapInstructionLines(lines []string, escapeCharacter rune) (st statement.Type, args statement.SourceMap, statementLines, remainingLines []string, err error) {
 	currentLine := strings.TrimSpace(lines[0])
 	remainingLines = lines[1:]
 
 	reMatches := instructionLineMatcher.FindStringSubmatch(currentLine)
 	if len(reMatches) != 2 {
-		return statement.Type(""), "", nil, lines, fmt.Errorf("syntax error: %q", currentLine)
+		return statement.Type(""), args, nil, lines, newParseError(SyntaxErrorCode, currentLine, "syntax error: %q", currentLine)
 	}
 
 	st = statement.Type(strings.ToUpper(reMatches[1]))
 	statementLines = append(statementLines, currentLine)
 
-	currentLine = strings.TrimSpace(currentLine[len(string(st)):]) // trim command from first line
+	column := len(lines[0]) - len(strings.TrimLeftFunc(lines[0], unicode.IsSpace)) + len(reMatches[1]) + 1
+	currentLine = currentLine[len(reMatches[1]):]
+	column += len(currentLine) - len(strings.TrimLeftFunc(currentLine, unicode.IsSpace))
+	currentLine = strings.TrimSpace(currentLine) // trim command from first line
 
 	terminated := true
 	if hasContinuation(currentLine, escapeCharacter) {
@@ -245,10 +353,12 @@ func scanInstructionLines(lines []string, escapeCharacter rune) (st statement.Ty
 		currentLine = strings.TrimSuffix(currentLine, string(escapeCharacter))
 	}
 
-	rawArgs = currentLine
+	args.Segments = append(args.Segments, statement.Segment{Start: statement.Position{Line: 1, Column: column}})
+	args.Text = currentLine
 
-	for !terminated && len(remainingLines) > 0 {
-		currentLine = strings.TrimSpace(remainingLines[0])
+	for lineNum := 2; !terminated && len(remainingLines) > 0; lineNum++ {
+		rawLine := remainingLines[0]
+		currentLine = strings.TrimSpace(rawLine)
 		remainingLines = remainingLines[1:]
 		if currentLine == "" {
 			// Ignore blank lines in multi-line statement.
@@ -266,8 +376,12 @@ func scanInstructionLines(lines []string, escapeCharacter rune) (st statement.Ty
 			terminated = true
 		}
 
-		rawArgs += currentLine
+		args.Segments = append(args.Segments, statement.Segment{
+			Offset: len(args.Text),
+			Start:  statement.Position{Line: lineNum, Column: len(rawLine) - len(strings.TrimLeftFunc(rawLine, unicode.IsSpace)) + 1},
+		})
+		args.Text += currentLine
 		statementLines = append(statementLines, currentLine)
 	}
-	return st, rawArgs, statementLines, remainingLines, nil
+	return st, args, statementLines, remainingLines, nil
 }
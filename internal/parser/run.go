@@ -1,7 +1,78 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+/*
+RUN instructions execute a command while building the image. There are 2 forms:
+
+	a) `RUN [--<flag>...] <command>`, run by the shell
+	b) `RUN [--<flag>...] ["<executable>", "<param>"...]`
+
+The shell form may reference heredocs. The `--mount` flag may be repeated.
+
+See: https://docs.docker.com/engine/reference/builder/#run
+*/
 func scanRUN(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.RUN {
+		return nil, lines, fmt.Errorf("not a RUN statement: %q", statementLines[0])
+	}
+	inst := &statement.RunInstruction{Lines: statementLines}
+
+	flags, rawArgs, err := scanFlags(rawArgs, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	for _, f := range flags {
+		switch f.Name {
+		case "mount":
+			var val string
+			if val, err = requireFlagValue(st, f); err != nil {
+				return nil, lines, err
+			}
+			m, err := ParseMount(val)
+			if err != nil {
+				return nil, lines, newParseError(InvalidArgumentsErrorCode, f.String(), "invalid RUN flag %q: %w", f.Name, err)
+			}
+			inst.Mounts = append(inst.Mounts, m)
+		case "network":
+			inst.Network, err = requireFlagValue(st, f)
+			if err == nil && !(inst.Network == "default" || inst.Network == "none" || inst.Network == "host" || hasVariableReference(inst.Network)) {
+				err = newParseError(InvalidArgumentsErrorCode, f.String(), "RUN network mode must be one of [default, none, host], got %q", inst.Network)
+			}
+		case "security":
+			inst.Security, err = requireFlagValue(st, f)
+			if err == nil && !(inst.Security == "sandbox" || inst.Security == "insecure" || hasVariableReference(inst.Security)) {
+				err = newParseError(InvalidArgumentsErrorCode, f.String(), "RUN security mode must be one of [sandbox, insecure], got %q", inst.Security)
+			}
+		default:
+			err = unknownFlagError(st, f)
+		}
+		if err != nil {
+			return nil, lines, err
+		}
+	}
+	if inst.FlagOrder, err = flagOrder(st, flags, "mount"); err != nil {
+		return nil, lines, err
+	}
+
+	if rawArgs == "" {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, "", "RUN requires a command")
+	}
+	if inst.Exec, err = parseJSONStringList(rawArgs); err == nil {
+		return inst, remainingLines, nil
+	}
+	inst.Command = rawArgs
+	if inst.Heredocs, remainingLines, err = scanHeredocs(shell.NewLexer(escapeCharacter).Fields(rawArgs), remainingLines); err != nil {
+		return nil, lines, err
+	}
+	return inst, remainingLines, nil
 }
@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// heredocInstructions are the instructions whose lines may be followed by here-document bodies, see scanHeredocs.
+var heredocInstructions = map[statement.Type]bool{
+	statement.ADD:  true,
+	statement.COPY: true,
+	statement.RUN:  true,
+}
+
+/*
+Scanner reads the statements of a Dockerfile one at a time:
+
+	s := NewScanner(r)
+	for s.Scan() {
+		stmt := s.Statement()
+		...
+	}
+	if err := s.Err(); err != nil {
+		...
+	}
+
+Lines may be of any length. Only the lines of the current statement, plus a lookahead of at most as many lines again,
+are held in memory at once.
+*/
+type Scanner struct {
+	// Recover continues scanning past statements which fail to parse, yielding a *statement.BadStatement for each.
+	// All of the failures are reported by Err as an ErrorList once scanning has finished.
+	Recover bool
+
+	reader          *bufio.Reader
+	eof             bool
+	started, done   bool
+	escapeCharacter rune
+	directives      []Directive
+
+	// lines are the lines which have been read but not yet scanned, the first of which is line number `lineNum`.
+	lines   []string
+	lineNum int
+
+	// readErr is the failure to read further lines, which is only fatal once those lines are needed.
+	readErr error
+
+	stmt   statement.Statement
+	err    error
+	errors ErrorList
+}
+
+// NewScanner returns a Scanner reading the Dockerfile from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		reader:          bufio.NewReader(r),
+		escapeCharacter: DefaultExcapeCharacter,
+		lineNum:         1,
+	}
+}
+
+// Statement is the statement read by the last call to Scan.
+func (s *Scanner) Statement() statement.Statement {
+	return s.stmt
+}
+
+// Directives are the parser directives at the top of the Dockerfile, in order of appearance.
+// They are known once Scan has been called.
+func (s *Scanner) Directives() []Directive {
+	return s.directives
+}
+
+// EscapeCharacter is the escape character of the Dockerfile, as set by the `escape` parser directive.
+// It is known once Scan has been called.
+func (s *Scanner) EscapeCharacter() rune {
+	return s.escapeCharacter
+}
+
+// Err is the failure which stopped scanning, nil if the end of the Dockerfile was reached.
+// Failures to parse are reported as a *ParseError, or an ErrorList of all of them when recovering.
+func (s *Scanner) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	if len(s.errors) > 0 {
+		return s.errors
+	}
+	return nil
+}
+
+// Scan advances to the next statement, which is then available through Statement.
+// It returns false once the end of the Dockerfile is reached or scanning fails, see Err.
+func (s *Scanner) Scan() bool {
+	s.stmt = nil
+	if s.done {
+		return false
+	}
+	if !s.started {
+		s.started = true
+		if err := s.scanParserDirectives(); err != nil {
+			return s.fail(err)
+		}
+		if s.done {
+			return false
+		}
+	}
+
+	for len(s.lines) == 0 || blankLineMatcher.MatchString(s.lines[0]) {
+		if len(s.lines) > 0 {
+			// skip blank lines
+			s.discard(1)
+			continue
+		}
+		if s.eof {
+			s.done = true
+			return false
+		}
+		if s.readErr != nil {
+			return s.fail(s.readErr)
+		}
+		s.readLines(1)
+	}
+
+	// Buffer all of the lines of the statement, plus at least one more, so that it is known to be complete.
+	for !s.eof && statementExtent(s.lines, s.escapeCharacter) >= len(s.lines) {
+		if s.readErr != nil {
+			return s.fail(s.readErr)
+		}
+		s.readLines(len(s.lines))
+	}
+
+	stmt, remainingLines, err := scanStatement(s.lines, s.escapeCharacter)
+	if err != nil {
+		pe := locateError(err, s.lines, s.lineNum, s.escapeCharacter)
+		if !s.Recover {
+			return s.fail(pe)
+		}
+		s.errors = append(s.errors, pe)
+		stmt, remainingLines = scanBadStatement(s.lines, s.escapeCharacter, pe)
+		for len(remainingLines) == 0 && !s.eof {
+			if s.readErr != nil {
+				return s.fail(s.readErr)
+			}
+			s.readLines(len(s.lines))
+			stmt, remainingLines = scanBadStatement(s.lines, s.escapeCharacter, pe)
+		}
+	}
+	consumed := len(s.lines) - len(remainingLines)
+	if l, ok := stmt.(locatable); ok {
+		l.SetLocation(locate(s.lines[:consumed], s.lineNum))
+		if _, isInstruction := stmt.(statement.Instruction); isInstruction {
+			l.SetArgumentsMap(mapArguments(s.lines[:consumed], s.lineNum, s.escapeCharacter))
+		}
+	}
+	s.discard(consumed)
+	s.stmt = stmt
+	return true
+}
+
+// scanParserDirectives consumes the parser directives at the top of the Dockerfile.
+func (s *Scanner) scanParserDirectives() error {
+	for !s.eof && (len(s.lines) == 0 || parserDirectiveMatcher.MatchString(s.lines[len(s.lines)-1])) {
+		if s.readErr != nil {
+			return s.readErr
+		}
+		s.readLines(1)
+	}
+	if len(s.lines) == 0 {
+		s.done = true
+		return s.recover(newParseError(EmptyFileErrorCode, "", "dockerfile was empty"))
+	}
+
+	directives, remainingLines, err := scanParserDirectives(s.lines)
+	if err != nil {
+		// Treat the would-be directives as ordinary comments.
+		if err := s.recover(err.(*ParseError)); err != nil {
+			return err
+		}
+	}
+	s.directives = directives
+	for _, d := range s.directives {
+		if d.Key == EscapeParserDirectiveKey {
+			s.escapeCharacter = []rune(d.Value)[0]
+		}
+	}
+	s.discard(len(s.lines) - len(remainingLines))
+	return nil
+}
+
+// recover records the failure if recovering, otherwise returns it.
+func (s *Scanner) recover(pe *ParseError) error {
+	if !s.Recover {
+		return pe
+	}
+	s.errors = append(s.errors, pe)
+	return nil
+}
+
+func (s *Scanner) fail(err error) bool {
+	s.err = err
+	s.done = true
+	return false
+}
+
+// readLines buffers up to n more lines, fewer if the end of the Dockerfile is reached or reading fails.
+// Lines which were read in full before a failure are still buffered, the failure is recorded in readErr.
+func (s *Scanner) readLines(n int) {
+	for ; n > 0 && !s.eof && s.readErr == nil; n-- {
+		line, err := s.reader.ReadString('\n')
+		if err == io.EOF {
+			s.eof = true
+			if line == "" {
+				break
+			}
+		} else if err != nil {
+			s.readErr = err
+			break
+		}
+		s.lines = append(s.lines, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+	}
+}
+
+// discard drops the first n buffered lines.
+func (s *Scanner) discard(n int) {
+	s.lines = s.lines[n:]
+	s.lineNum += n
+	if len(s.lines) == 0 {
+		// release the backing array
+		s.lines = nil
+	}
+}
+
+// statementExtent is the number of lines at the start of the given lines which make up the next statement.
+// It is the number of lines given if the statement may continue past them.
+func statementExtent(lines []string, escapeCharacter rune) int {
+	if commentLineMatcher.MatchString(lines[0]) {
+		_, remainingLines, _ := scanComment(lines)
+		return len(lines) - len(remainingLines)
+	}
+	if reMatches := instructionLineMatcher.FindStringSubmatch(strings.TrimSpace(lines[0])); len(reMatches) != 2 || !statement.Known[statement.Type(strings.ToUpper(reMatches[1]))] {
+		// fails to parse as an instruction, see scanInstruction
+		return 1
+	}
+	st, rawArgs, _, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return 1
+	}
+	if heredocInstructions[st] {
+		if _, afterHeredocs, err := scanHeredocs(shell.NewLexer(escapeCharacter).Fields(rawArgs), remainingLines); err == nil {
+			remainingLines = afterHeredocs
+		} else {
+			// unterminated, the heredoc runs until the end of the Dockerfile
+			remainingLines = nil
+		}
+	}
+	return len(lines) - len(remainingLines)
+}
@@ -1,7 +1,38 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// SHELL is an instruction of the form:
+// `SHELL ["<executable>", "<parameters>"...]`
+// which must be written as a JSON array.
+// See: https://docs.docker.com/engine/reference/builder/#shell
 func scanSHELL(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.SHELL {
+		return nil, lines, fmt.Errorf("not a SHELL statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	rawArgs = strings.TrimSpace(rawArgs)
+	shell, err := parseJSONStringList(rawArgs)
+	if err != nil {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "SHELL requires the arguments to be in JSON form, e.g. `SHELL [\"/bin/sh\", \"-c\"]`, got %q", rawArgs)
+	}
+	if len(shell) == 0 {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, rawArgs, "SHELL requires at least one argument")
+	}
+	return &statement.ShellInstruction{
+		Shell: shell,
+		Lines: statementLines,
+	}, remainingLines, nil
 }
@@ -1,7 +1,71 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// signalNames are the Linux signals which can be referred to by name, with or without the `SIG` prefix.
+var signalNames = map[string]bool{
+	"ABRT": true, "ALRM": true, "BUS": true, "CHLD": true, "CLD": true, "CONT": true, "FPE": true,
+	"HUP": true, "ILL": true, "INT": true, "IO": true, "IOT": true, "KILL": true, "PIPE": true,
+	"POLL": true, "PROF": true, "PWR": true, "QUIT": true, "SEGV": true, "STKFLT": true, "STOP": true,
+	"SYS": true, "TERM": true, "TRAP": true, "TSTP": true, "TTIN": true, "TTOU": true, "UNUSED": true,
+	"URG": true, "USR1": true, "USR2": true, "VTALRM": true, "WINCH": true, "XCPU": true, "XFSZ": true,
+}
+
+// realtimeSignalMatcher matches the real-time signals, e.g. `RTMIN+3` or `RTMAX-1`.
+var realtimeSignalMatcher = regexp.MustCompile(reStartOfLine + `RTM(?:IN(?:\+[0-9]+)?|AX(?:-[0-9]+)?)` + reEndOfLine)
+
+// STOPSIGNAL is an instruction of the form:
+// `STOPSIGNAL <signal>`
+// where the signal is a name, e.g. `SIGKILL` or `KILL`, or a number, e.g. `9`.
+// See: https://docs.docker.com/engine/reference/builder/#stopsignal
 func scanSTOPSIGNAL(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.STOPSIGNAL {
+		return nil, lines, fmt.Errorf("not a STOPSIGNAL statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	args := shell.NewLexer(escapeCharacter).Fields(rawArgs)
+	if len(args) != 1 {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, strings.TrimSpace(rawArgs), "STOPSIGNAL requires exactly one argument, got %q", rawArgs)
+	}
+	if err := validateSignal(args[0]); err != nil {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, args[0], "%v", err)
+	}
+	return &statement.StopSignalInstruction{
+		Signal: args[0],
+		Lines:  statementLines,
+	}, remainingLines, nil
+}
+
+// validateSignal checks that the signal is a known name or a positive number.
+// Signals containing variable references can only be validated once resolved.
+func validateSignal(signal string) error {
+	if hasVariableReference(signal) {
+		return nil
+	}
+	if n, err := strconv.ParseUint(signal, 10, 8); err == nil {
+		if n == 0 {
+			return fmt.Errorf("invalid signal number %q", signal)
+		}
+		return nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+	if !signalNames[name] && !realtimeSignalMatcher.MatchString(name) {
+		return fmt.Errorf("invalid signal %q, must be a signal name such as SIGTERM or a signal number", signal)
+	}
+	return nil
 }
@@ -1,7 +1,60 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// USER is an instruction of the form:
+// `USER <user>[:<group>]`
+// where the user and group are names or numeric IDs.
+// See: https://docs.docker.com/engine/reference/builder/#user
 func scanUSER(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.USER {
+		return nil, lines, fmt.Errorf("not a USER statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	args := shell.NewLexer(escapeCharacter).Fields(rawArgs)
+	if len(args) != 1 {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, strings.TrimSpace(rawArgs), "USER requires exactly one argument of the form `<user>[:<group>]`, got %q", rawArgs)
+	}
+	inst := &statement.UserInstruction{Lines: statementLines}
+	inst.RawUser = args[0]
+	if i := userGroupSeparator(args[0]); i != -1 {
+		inst.RawUser, inst.RawGroup = args[0][:i], args[0][i+1:]
+		if inst.RawUser == "" || inst.RawGroup == "" {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, args[0], "USER must be of the form `<user>[:<group>]`, got %q", args[0])
+		}
+	}
+	inst.User = statement.Unquote(inst.RawUser, escapeCharacter)
+	inst.Group = statement.Unquote(inst.RawGroup, escapeCharacter)
+	return inst, remainingLines, nil
+}
+
+// userGroupSeparator is the index of the `:` separating the user from the group, or -1 if there is none.
+// Colons within variable references, e.g. `${UID:-1000}`, are not separators.
+func userGroupSeparator(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}' && depth > 0:
+			depth--
+		case s[i] == ':' && depth == 0:
+			return i
+		}
+	}
+	return -1
 }
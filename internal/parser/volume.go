@@ -1,7 +1,43 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// VOLUME is an instruction of the forms:
+// `VOLUME ["<path>", ...]`
+// `VOLUME <path> [<path>...]`
+// See: https://docs.docker.com/engine/reference/builder/#volume
 func scanVOLUME(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.VOLUME {
+		return nil, lines, fmt.Errorf("not a VOLUME statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	inst := &statement.VolumeInstruction{Lines: statementLines}
+	if paths, err := parseJSONStringList(rawArgs); err == nil {
+		inst.Paths = paths
+		inst.Execable = true
+	} else {
+		inst.Paths = shell.NewLexer(escapeCharacter).Fields(rawArgs)
+	}
+	if len(inst.Paths) == 0 {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, strings.TrimSpace(rawArgs), "VOLUME requires at least one argument")
+	}
+	for _, path := range inst.Paths {
+		if strings.TrimSpace(path) == "" {
+			return nil, lines, newParseError(InvalidArgumentsErrorCode, strings.TrimSpace(rawArgs), "VOLUME specified can not be an empty string")
+		}
+	}
+	return inst, remainingLines, nil
 }
@@ -1,7 +1,35 @@
 package parser
 
-import "github.com/dekkagaijin/go-dockerfile/statement"
+import (
+	"fmt"
+	"strings"
 
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// WORKDIR is an instruction of the form:
+// `WORKDIR <path>`
+// where the path may be absolute or relative to the previous WORKDIR.
+// See: https://docs.docker.com/engine/reference/builder/#workdir
 func scanWORKDIR(lines []string, escapeCharacter rune) (stmt statement.Statement, remainingLines []string, err error) {
-	return scanGenericInstruction(lines, escapeCharacter)
+	st, rawArgs, statementLines, remainingLines, err := scanInstructionLines(lines, escapeCharacter)
+	if err != nil {
+		return nil, lines, err
+	}
+	if st != statement.WORKDIR {
+		return nil, lines, fmt.Errorf("not a WORKDIR statement: %q", statementLines[0])
+	}
+	if rawArgs, err = scanNoFlags(st, rawArgs, escapeCharacter); err != nil {
+		return nil, lines, err
+	}
+
+	path := strings.TrimSpace(rawArgs)
+	if path == "" {
+		return nil, lines, newParseError(InvalidArgumentsErrorCode, "", "WORKDIR requires exactly one argument")
+	}
+	return &statement.WorkdirInstruction{
+		Path:    statement.Unquote(path, escapeCharacter),
+		RawPath: path,
+		Lines:   statementLines,
+	}, remainingLines, nil
 }
@@ -1,8 +1,9 @@
 package dockerfile
 
 import (
-	"bufio"
+	"errors"
 	"io"
+	"strings"
 
 	"github.com/dekkagaijin/go-dockerfile/internal/parser"
 	"github.com/dekkagaijin/go-dockerfile/statement"
@@ -12,27 +13,166 @@ const (
 	DefaultExcapeCharacter = parser.DefaultExcapeCharacter
 	WindowsEscapeCharacter = parser.WindowsEscapeCharacter
 	CommentToken           = parser.CommentToken
+
+	EscapeParserDirectiveKey = parser.EscapeParserDirectiveKey
+	SyntaxParserDirectiveKey = parser.SyntaxParserDirectiveKey
+	CheckParserDirectiveKey  = parser.CheckParserDirectiveKey
 )
 
+// ParseError describes a failure to parse a Dockerfile, see ErrorCode for the kinds of failure.
+type ParseError = parser.ParseError
+
+// ErrorCode is a machine-readable identifier for the kind of a ParseError.
+type ErrorCode = parser.ErrorCode
+
+const (
+	EmptyFileErrorCode           = parser.EmptyFileErrorCode
+	InvalidDirectiveErrorCode    = parser.InvalidDirectiveErrorCode
+	SyntaxErrorCode              = parser.SyntaxErrorCode
+	UnknownInstructionErrorCode  = parser.UnknownInstructionErrorCode
+	InvalidArgumentsErrorCode    = parser.InvalidArgumentsErrorCode
+	UnterminatedHeredocErrorCode = parser.UnterminatedHeredocErrorCode
+)
+
+// ErrorList is the list of failures encountered while parsing a Dockerfile with error recovery.
+type ErrorList = parser.ErrorList
+
+// Scanner reads the statements of a Dockerfile one at a time, with no limit on the length of lines.
+type Scanner = parser.Scanner
+
+// NewScanner returns a Scanner reading the Dockerfile from r.
+func NewScanner(r io.Reader) *Scanner {
+	return parser.NewScanner(r)
+}
+
+// Directive is a parser directive, e.g. `# syntax=docker/dockerfile:1`.
+type Directive = parser.Directive
+
 type Parsed struct {
 	Statements      []statement.Statement
 	EscapeCharacter rune
+	// Directives are the parser directives at the top of the Dockerfile, in order of appearance.
+	// The value of the `escape` directive, if any, is superseded by EscapeCharacter.
+	Directives []Directive
+
+	// source is the original text of a losslessly parsed Dockerfile, nil otherwise.
+	source *source
+}
+
+// source is the original text of a Dockerfile, split up by statement.
+type source struct {
+	// header is the text preceding the first statement, i.e. parser directives and blank lines.
+	header string
+	// canonicalHeader is the normalized rendering of the parser directives at the time of parsing.
+	canonicalHeader string
+	statements      map[statement.Statement]statementSource
+	// trailer is the text following the last statement.
+	trailer      string
+	finalNewline bool
+	// newline is the line ending of the Dockerfile, i.e. that of its first line, used to end lines which are rendered anew.
+	newline string
+}
+
+type statementSource struct {
+	// leading is the text between the previous statement and this one, e.g. blank lines.
+	leading string
+	// text is the original text of the statement, including its final line ending.
+	text string
+	// canonical is the normalized rendering of the statement at the time of parsing, used to detect modification.
+	canonical string
+}
+
+// lineEnding is the line ending of the last line of the statement's original text.
+func (s statementSource) lineEnding() string {
+	if strings.HasSuffix(s.text, "\r\n") {
+		return "\r\n"
+	}
+	if strings.HasSuffix(s.text, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// Parser parses Dockerfiles.
+type Parser struct {
+	// Lossless retains the original text of the Dockerfile, so that rendering reproduces
+	// casing, whitespace, continuations, blank lines and comments of unmodified statements byte-for-byte.
+	Lossless bool
+	// Recover continues parsing past statements which fail to parse, replacing each with a *statement.BadStatement
+	// spanning the broken region. Parsing resumes at the next blank line, comment or instruction keyword.
+	// Parse then returns the partially parsed Dockerfile along with an ErrorList of every failure.
+	Recover bool
+}
+
+// Parse parses the given Dockerfile. Failures to parse are reported as a *ParseError, or an ErrorList when recovering.
+func (p Parser) Parse(file io.Reader) (*Parsed, error) {
+	var original strings.Builder
+	if p.Lossless {
+		file = io.TeeReader(file, &original)
+	}
+	s := NewScanner(file)
+	s.Recover = p.Recover
+
+	parsed := &Parsed{}
+	for s.Scan() {
+		parsed.Statements = append(parsed.Statements, s.Statement())
+	}
+	parseErr := s.Err()
+	var recovered ErrorList
+	if parseErr != nil && !errors.As(parseErr, &recovered) {
+		// failed to parse without recovering, or failed to read
+		return nil, parseErr
+	}
+	parsed.EscapeCharacter = s.EscapeCharacter()
+	parsed.Directives = s.Directives()
+	if p.Lossless {
+		rawLines := strings.SplitAfter(original.String(), "\n")
+		if rawLines[len(rawLines)-1] == "" {
+			rawLines = rawLines[:len(rawLines)-1]
+		}
+		var err error
+		if parsed.source, err = newSource(parsed, rawLines); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, parseErr
+}
+
+// newSource splits the original lines of the Dockerfile up between its parsed statements.
+func newSource(df *Parsed, rawLines []string) (*source, error) {
+	src := &source{
+		canonicalHeader: renderHeader(df),
+		statements:      make(map[statement.Statement]statementSource, len(df.Statements)),
+		finalNewline:    len(rawLines) > 0 && strings.HasSuffix(rawLines[len(rawLines)-1], "\n"),
+		newline:         "\n",
+	}
+	if len(rawLines) > 0 && strings.HasSuffix(rawLines[0], "\r\n") {
+		src.newline = "\r\n"
+	}
+	consumed := 0 // number of raw lines attributed so far
+	for i, stmt := range df.Statements {
+		loc := stmt.Location()
+		leading := strings.Join(rawLines[consumed:loc.Start.Line-1], "")
+		if i == 0 {
+			src.header = leading
+			leading = ""
+		}
+		canonical, err := renderStatement(stmt, escapeCharacterOf(df))
+		if err != nil {
+			return nil, err
+		}
+		src.statements[stmt] = statementSource{
+			leading:   leading,
+			text:      strings.Join(rawLines[loc.Start.Line-1:loc.End.Line], ""),
+			canonical: canonical,
+		}
+		consumed = loc.End.Line
+	}
+	src.trailer = strings.Join(rawLines[consumed:], "")
+	return src, nil
 }
 
-// Parse parses the given Dockerfile.
+// Parse parses the given Dockerfile, normalizing its formatting.
 func Parse(file io.Reader) (*Parsed, error) {
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	sp := parser.Sequential{}
-	statements, escapeChar, err := sp.Parse(lines)
-	if err != nil {
-		return nil, err
-	}
-	return &Parsed{
-		Statements:      statements,
-		EscapeCharacter: escapeChar,
-	}, nil
+	return Parser{}.Parse(file)
 }
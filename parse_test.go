@@ -0,0 +1,1086 @@
+package dockerfile
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dekkagaijin/go-dockerfile/statement"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseLocations(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"# escape=\\",
+		"",
+		"# comment 1",
+		"# comment 2",
+		"  FROM image AS build",
+		"RUN cmd \\",
+		"",
+		"    # interstitial comment",
+		"    arg1 \\",
+		"    arg2  ",
+		"",
+		"ENV A=b \\",
+		"",
+	}, "\n")
+
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+
+	var got []statement.Range
+	for _, stmt := range parsed.Statements {
+		got = append(got, stmt.Location())
+	}
+	want := []statement.Range{
+		{Start: statement.Position{Line: 3, Column: 1}, End: statement.Position{Line: 4, Column: 12}},
+		{Start: statement.Position{Line: 5, Column: 3}, End: statement.Position{Line: 5, Column: 22}},
+		{Start: statement.Position{Line: 6, Column: 1}, End: statement.Position{Line: 10, Column: 9}},
+		{Start: statement.Position{Line: 12, Column: 1}, End: statement.Position{Line: 12, Column: 10}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"# syntax=docker/dockerfile:1.4",
+		"# check=skip=JSONArgsRecommended;error=true",
+		"",
+		"FROM image",
+	}, "\n")
+
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	wantDirectives := []Directive{
+		{Key: SyntaxParserDirectiveKey, Value: "docker/dockerfile:1.4"},
+		{Key: CheckParserDirectiveKey, Value: "skip=JSONArgsRecommended;error=true"},
+	}
+	if diff := cmp.Diff(wantDirectives, parsed.Directives); diff != "" {
+		t.Error("Directives mismatch (-want +got):\n", diff)
+	}
+	if got, want := parsed.Syntax(), "docker/dockerfile:1.4"; got != want {
+		t.Errorf("Syntax() = %q, want %q", got, want)
+	}
+	if got, want := parsed.Check(), "skip=JSONArgsRecommended;error=true"; got != want {
+		t.Errorf("Check() = %q, want %q", got, want)
+	}
+
+	parsed.SetSyntax("docker/dockerfile:1")
+	parsed.SetCheck("")
+	if err := parsed.SetDirective(EscapeParserDirectiveKey, "|"); err == nil {
+		t.Error("SetDirective() of an invalid escape character succeeded, want an error")
+	}
+	if err := parsed.SetDirective(EscapeParserDirectiveKey, string(WindowsEscapeCharacter)); err != nil {
+		t.Fatalf("SetDirective() error'd: %v", err)
+	}
+
+	sb := strings.Builder{}
+	if err := Render(parsed, &sb); err != nil {
+		t.Fatalf("Render() error'd: %v", err)
+	}
+	expected := strings.Join([]string{
+		"# syntax=docker/dockerfile:1",
+		"# escape=`",
+		"",
+		"FROM image",
+	}, "\n")
+	if diff := cmp.Diff(expected, sb.String()); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		dockerfile string
+		expected   ParseError
+	}{
+		{
+			desc:       "empty",
+			dockerfile: "",
+			expected:   ParseError{Code: EmptyFileErrorCode},
+		},
+		{
+			desc:       "invalid escape directive",
+			dockerfile: "# syntax=docker/dockerfile:1\n  # escape=|\nFROM image",
+			expected: ParseError{
+				Line:    2,
+				Column:  3,
+				Snippet: "# escape=|",
+				Code:    InvalidDirectiveErrorCode,
+			},
+		},
+		{
+			desc:       "unknown instruction",
+			dockerfile: "FROM image\nRUN a \\\n  b\n  FOO bar",
+			expected: ParseError{
+				Line:        4,
+				Column:      3,
+				Instruction: "FOO",
+				Snippet:     "FOO",
+				Code:        UnknownInstructionErrorCode,
+			},
+		},
+		{
+			desc:       "non-ASCII unknown instruction",
+			dockerfile: "FROM image\nɐɐɐ 0\n",
+			expected: ParseError{
+				Line:        2,
+				Column:      1,
+				Instruction: "ⱯⱯⱯ",
+				Snippet:     "ɐɐɐ",
+				Code:        UnknownInstructionErrorCode,
+			},
+		},
+		{
+			desc:       "invalid arguments",
+			dockerfile: "FROM image\nARG \\\n\n  =oops\nRUN make",
+			expected: ParseError{
+				Line:        4,
+				Column:      3,
+				Instruction: statement.ARG,
+				Snippet:     "=oops",
+				Code:        InvalidArgumentsErrorCode,
+			},
+		},
+		{
+			desc:       "snippet within instruction name",
+			dockerfile: "FROM image\n  STOPSIGNAL S",
+			expected: ParseError{
+				Line:        2,
+				Column:      14,
+				Instruction: statement.STOPSIGNAL,
+				Snippet:     "S",
+				Code:        InvalidArgumentsErrorCode,
+			},
+		},
+		{
+			desc:       "snippet within instruction name on continuation line",
+			dockerfile: "FROM image\nEXPOSE 80 \\\n  E",
+			expected: ParseError{
+				Line:        3,
+				Column:      3,
+				Instruction: statement.EXPOSE,
+				Snippet:     "E",
+				Code:        InvalidArgumentsErrorCode,
+			},
+		},
+		{
+			desc:       "missing arguments",
+			dockerfile: "FROM image\nLABEL",
+			expected: ParseError{
+				Line:        2,
+				Column:      1,
+				Instruction: statement.LABEL,
+				Snippet:     "LABEL",
+				Code:        SyntaxErrorCode,
+			},
+		},
+		{
+			desc:       "unterminated heredoc",
+			dockerfile: "FROM image\ncopy <<EOF /dst\ncontent\nEOF \n",
+			expected: ParseError{
+				Line:        2,
+				Column:      6,
+				Instruction: statement.COPY,
+				Snippet:     "<<EOF",
+				Code:        UnterminatedHeredocErrorCode,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse(strings.NewReader(tc.dockerfile))
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse() error = %v, want a *ParseError", err)
+			}
+			if diff := cmp.Diff(tc.expected, *pe, cmpopts.IgnoreFields(ParseError{}, "Err")); diff != "" {
+				t.Error("mismatch (-want +got):\n", diff)
+			}
+		})
+	}
+}
+
+func TestParseRecover(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM image",
+		"FOO bar \\",
+		"  baz",
+		"  qux",
+		"# comment",
+		"ARG =oops",
+		"RUN make",
+		"COPY <<EOF /dst",
+		"content",
+		"RUN make install",
+	}, "\n")
+
+	parsed, err := Parser{Recover: true, Lossless: true}.Parse(strings.NewReader(dockerfile))
+	var errs ErrorList
+	if !errors.As(err, &errs) {
+		t.Fatalf("Parse() error = %v, want an ErrorList", err)
+	}
+	var gotErrs []string
+	for _, e := range errs {
+		gotErrs = append(gotErrs, fmt.Sprintf("%d:%d %s", e.Line, e.Column, e.Code))
+	}
+	wantErrs := []string{
+		"2:1 unknown-instruction",
+		"6:5 invalid-arguments",
+		"8:6 unterminated-heredoc",
+	}
+	if diff := cmp.Diff(wantErrs, gotErrs); diff != "" {
+		t.Error("errors mismatch (-want +got):\n", diff)
+	}
+	var first *ParseError
+	if !errors.As(err, &first) || first != errs[0] {
+		t.Errorf("errors.As(%v) did not yield the first *ParseError", err)
+	}
+
+	var gotStmts []string
+	for _, stmt := range parsed.Statements {
+		gotStmts = append(gotStmts, fmt.Sprintf("%s %s", stmt.Type(), stmt.Location()))
+	}
+	wantStmts := []string{
+		"FROM 1:1-1:11",
+		"<bad> 2:1-4:6",
+		"# 5:1-5:10",
+		"<bad> 6:1-6:10",
+		"RUN 7:1-7:9",
+		"<bad> 8:1-9:8",
+		"RUN 10:1-10:17",
+	}
+	if diff := cmp.Diff(wantStmts, gotStmts); diff != "" {
+		t.Error("statements mismatch (-want +got):\n", diff)
+	}
+
+	sb := strings.Builder{}
+	if err := Render(parsed, &sb); err != nil {
+		t.Fatalf("Render() error'd: %v", err)
+	}
+	if diff := cmp.Diff(dockerfile, sb.String()); diff != "" {
+		t.Error("render mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestParseRecoverNonASCIIInstruction(t *testing.T) {
+	dockerfile := "FROM image\n\xf7\x82 0 \\\n  1\nRUN make"
+
+	parsed, err := Parser{Recover: true}.Parse(strings.NewReader(dockerfile))
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Code != UnknownInstructionErrorCode || pe.Line != 2 {
+		t.Fatalf("Parse() error = %v, want an unknown instruction on line 2", err)
+	}
+	var gotStmts []string
+	for _, stmt := range parsed.Statements {
+		gotStmts = append(gotStmts, fmt.Sprintf("%s %s", stmt.Type(), stmt.Location()))
+	}
+	wantStmts := []string{
+		"FROM 1:1-1:11",
+		"<bad> 2:1-3:4",
+		"RUN 4:1-4:9",
+	}
+	if diff := cmp.Diff(wantStmts, gotStmts); diff != "" {
+		t.Error("statements mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestParseInstructions(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		instruction string
+		expected    statement.Statement
+	}{
+		{
+			desc:        "ARG",
+			instruction: `ARG A=1 B C="x y" D= E=\$HOME`,
+			expected: &statement.ArgInstruction{
+				Declarations: []statement.ArgDeclaration{
+					{Name: "A", HasDefault: true, Default: "1", RawDefault: "1"},
+					{Name: "B"},
+					{Name: "C", HasDefault: true, Default: "x y", RawDefault: `"x y"`},
+					{Name: "D", HasDefault: true},
+					{Name: "E", HasDefault: true, Default: "$HOME", RawDefault: `\$HOME`},
+				},
+			},
+		},
+		{
+			desc:        "FROM with alias",
+			instruction: "FROM --platform=linux/amd64 golang:1.21 AS builder",
+			expected: &statement.FromInstruction{
+				Platform: "linux/amd64",
+				Image:    "golang:1.21",
+				Alias:    "builder",
+			},
+		},
+		{
+			desc:        "ADD with flags",
+			instruction: "ADD --checksum=sha256:abc --keep-git-dir --link --chown=app --exclude=*.md https://example.com/repo.git /src",
+			expected: &statement.AddInstruction{
+				Chown:      "app",
+				Checksum:   "sha256:abc",
+				KeepGitDir: true,
+				Link:       true,
+				Exclude:    []string{"*.md"},
+				Args:       statement.Arguments{List: []string{"https://example.com/repo.git", "/src"}},
+			},
+		},
+		{
+			desc:        "COPY with quoted flag",
+			instruction: `COPY --chown="app user" --exclude='*.tmp' . /app`,
+			expected: &statement.CopyInstruction{
+				Chown:   "app user",
+				Exclude: []string{"*.tmp"},
+				Sources: []string{"."},
+				Dest:    "/app",
+			},
+		},
+		{
+			desc:        "COPY with flags",
+			instruction: "COPY --from=build --chown=app --link --exclude=*.md --exclude=*.txt a b /dst/",
+			expected: &statement.CopyInstruction{
+				From:    "build",
+				Chown:   "app",
+				Link:    true,
+				Exclude: []string{"*.md", "*.txt"},
+				Sources: []string{"a", "b"},
+				Dest:    "/dst/",
+			},
+		},
+		{
+			desc:        "COPY exec form",
+			instruction: `COPY --parents=false ["my file", "/dst/"]`,
+			expected: &statement.CopyInstruction{
+				Sources:  []string{"my file"},
+				Dest:     "/dst/",
+				Execable: true,
+			},
+		},
+		{
+			desc:        "COPY quoted sources",
+			instruction: `COPY "my file" 'other  file' my\ file /dst/`,
+			expected: &statement.CopyInstruction{
+				Sources: []string{`"my file"`, `'other  file'`, `my\ file`},
+				Dest:    "/dst/",
+			},
+		},
+		{
+			desc:        "CMD shell form",
+			instruction: `CMD echo "a  b" 'c'`,
+			expected: &statement.GenericInstruction{
+				InstructionType: statement.CMD,
+				Args:            statement.Arguments{List: []string{"echo", `"a  b"`, "'c'"}},
+			},
+		},
+		{
+			desc:        "RUN with mounts",
+			instruction: "RUN --mount=type=cache,id=gomod,target=/go/pkg/mod,ro,mode=0755,uid=1000,gid=$GID --mount=type=secret,id=token,env=TOKEN --network=host go  mod download",
+			expected: &statement.RunInstruction{
+				Mounts: []statement.Mount{
+					&statement.CacheMount{ID: "gomod", Target: "/go/pkg/mod", ReadOnly: true, Mode: "0755", UID: "1000", GID: "$GID"},
+					&statement.SecretMount{ID: "token", Env: "TOKEN"},
+				},
+				Network: "host",
+				Command: "go  mod download",
+			},
+		},
+		{
+			desc:        "RUN with secret source",
+			instruction: "RUN --mount=type=secret,id=aws,src=/run/aws.creds,target=/root/.aws/credentials aws s3 ls",
+			expected: &statement.RunInstruction{
+				Mounts: []statement.Mount{
+					&statement.SecretMount{ID: "aws", Source: "/run/aws.creds", Target: "/root/.aws/credentials"},
+				},
+				Command: "aws s3 ls",
+			},
+		},
+		{
+			desc:        "RUN with read-only bind mounts",
+			instruction: "RUN --mount=target=/src,readonly --mount=target=/out,ro=false --mount=target=/tmp/x,rw make",
+			expected: &statement.RunInstruction{
+				Mounts: []statement.Mount{
+					&statement.BindMount{Target: "/src"},
+					&statement.BindMount{Target: "/out", ReadWrite: true},
+					&statement.BindMount{Target: "/tmp/x", ReadWrite: true},
+				},
+				Command: "make",
+			},
+		},
+		{
+			desc:        "RUN exec form",
+			instruction: `RUN --security=sandbox ["echo", "hello world"]`,
+			expected: &statement.RunInstruction{
+				Security: "sandbox",
+				Exec:     []string{"echo", "hello world"},
+			},
+		},
+		{
+			desc:        "HEALTHCHECK CMD",
+			instruction: "HEALTHCHECK --interval=1m30s --timeout=10s --start-period=0s --retries=5 CMD curl -f http://localhost/",
+			expected: &statement.HealthcheckInstruction{
+				Interval: 90 * time.Second,
+				Timeout:  10 * time.Second,
+				Retries:  5,
+				Cmd: &statement.GenericInstruction{
+					InstructionType: statement.CMD,
+					Args:            statement.Arguments{List: []string{"curl", "-f", "http://localhost/"}},
+				},
+			},
+		},
+		{
+			desc:        "HEALTHCHECK NONE",
+			instruction: "HEALTHCHECK NONE",
+			expected:    &statement.HealthcheckInstruction{None: true},
+		},
+		{
+			desc:        "LABEL",
+			instruction: `LABEL "com.example.vendor"="ACME Inc" version=1.0 desc='a b' empty=`,
+			expected: &statement.LabelInstruction{
+				Labels: []statement.Label{
+					{Key: "com.example.vendor", Value: "ACME Inc", RawKey: `"com.example.vendor"`, RawValue: `"ACME Inc"`},
+					{Key: "version", Value: "1.0", RawKey: "version", RawValue: "1.0"},
+					{Key: "desc", Value: "a b", RawKey: "desc", RawValue: "'a b'"},
+					{Key: "empty", Value: "", RawKey: "empty", RawValue: ""},
+				},
+			},
+		},
+		{
+			desc:        "LABEL escaped",
+			instruction: `LABEL quote="say \"hi\"" path=C:\\dir spaced=a\ b`,
+			expected: &statement.LabelInstruction{
+				Labels: []statement.Label{
+					{Key: "quote", Value: `say "hi"`, RawKey: "quote", RawValue: `"say \"hi\""`},
+					{Key: "path", Value: `C:\dir`, RawKey: "path", RawValue: `C:\\dir`},
+					{Key: "spaced", Value: "a b", RawKey: "spaced", RawValue: `a\ b`},
+				},
+			},
+		},
+		{
+			desc:        "LABEL legacy",
+			instruction: "LABEL description some text",
+			expected: &statement.LabelInstruction{
+				Labels: []statement.Label{
+					{Key: "description", Value: "some text", RawKey: "description", RawValue: "some text"},
+				},
+			},
+		},
+		{
+			desc:        "EXPOSE",
+			instruction: "EXPOSE 80 443/TCP 8000-8010/udp 9000/sctp ${PORT}",
+			expected: &statement.ExposeInstruction{
+				Ports: []statement.PortSpec{
+					{Raw: "80", Start: 80, End: 80, Protocol: statement.TCP},
+					{Raw: "443/TCP", Start: 443, End: 443, Protocol: statement.TCP},
+					{Raw: "8000-8010/udp", Start: 8000, End: 8010, Protocol: statement.UDP},
+					{Raw: "9000/sctp", Start: 9000, End: 9000, Protocol: statement.SCTP},
+					{Raw: "${PORT}"},
+				},
+			},
+		},
+		{
+			desc:        "USER",
+			instruction: "USER app",
+			expected:    &statement.UserInstruction{User: "app", RawUser: "app"},
+		},
+		{
+			desc:        "USER with group",
+			instruction: "USER 1000:${GID}",
+			expected:    &statement.UserInstruction{User: "1000", Group: "${GID}", RawUser: "1000", RawGroup: "${GID}"},
+		},
+		{
+			desc:        "USER with modified variables",
+			instruction: "USER ${UID:-1000}:${GID:?}",
+			expected:    &statement.UserInstruction{User: "${UID:-1000}", Group: "${GID:?}", RawUser: "${UID:-1000}", RawGroup: "${GID:?}"},
+		},
+		{
+			desc:        "USER quoted",
+			instruction: `USER "root":'wheel'`,
+			expected:    &statement.UserInstruction{User: "root", Group: "wheel", RawUser: `"root"`, RawGroup: `'wheel'`},
+		},
+		{
+			desc:        "WORKDIR",
+			instruction: `WORKDIR /my app`,
+			expected:    &statement.WorkdirInstruction{Path: "/my app", RawPath: "/my app"},
+		},
+		{
+			desc:        "WORKDIR quoted",
+			instruction: `WORKDIR "C:\\Program Files\\app"`,
+			expected:    &statement.WorkdirInstruction{Path: `C:\Program Files\app`, RawPath: `"C:\\Program Files\\app"`},
+		},
+		{
+			desc:        "STOPSIGNAL",
+			instruction: "STOPSIGNAL SIGRTMIN+3",
+			expected:    &statement.StopSignalInstruction{Signal: "SIGRTMIN+3"},
+		},
+		{
+			desc:        "VOLUME",
+			instruction: "VOLUME /data /var/log",
+			expected:    &statement.VolumeInstruction{Paths: []string{"/data", "/var/log"}},
+		},
+		{
+			desc:        "VOLUME exec form",
+			instruction: `VOLUME ["/my data"]`,
+			expected:    &statement.VolumeInstruction{Paths: []string{"/my data"}, Execable: true},
+		},
+		{
+			desc:        "SHELL",
+			instruction: `SHELL ["powershell", "-Command"]`,
+			expected:    &statement.ShellInstruction{Shell: []string{"powershell", "-Command"}},
+		},
+		{
+			desc:        "ONBUILD COPY",
+			instruction: "ONBUILD COPY --chown=app . /app",
+			expected: &statement.OnBuildInstruction{
+				Trigger: &statement.CopyInstruction{
+					Chown:   "app",
+					Sources: []string{"."},
+					Dest:    "/app",
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			parsed, err := Parse(strings.NewReader("FROM image\n" + tc.instruction))
+			if err != nil {
+				t.Fatalf("Parse() error'd: %v", err)
+			}
+			got := parsed.Statements[len(parsed.Statements)-1]
+			if diff := cmp.Diff(tc.expected, got, ignoreSourceFields); diff != "" {
+				t.Error("mismatch (-want +got):\n", diff)
+			}
+		})
+	}
+}
+
+func TestParseInstructionErrors(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		instruction string
+		snippet     string
+		// message is part of the error message, if set.
+		message string
+	}{
+		{
+			desc:        "COPY unknown flag",
+			instruction: "COPY --form=build a /dst",
+			snippet:     "--form=build",
+		},
+		{
+			desc:        "COPY flag without value",
+			instruction: "COPY --from a /dst",
+			snippet:     "--from",
+		},
+		{
+			desc:        "COPY invalid boolean",
+			instruction: "COPY --link=maybe a /dst",
+			snippet:     "--link=maybe",
+		},
+		{
+			desc:        "COPY missing destination",
+			instruction: "COPY --link a",
+			snippet:     "a",
+		},
+		{
+			desc:        "RUN unknown mount type",
+			instruction: "RUN --mount=type=nfs,target=/mnt ls",
+			snippet:     "--mount=type=nfs,target=/mnt",
+		},
+		{
+			desc:        "RUN unknown mount option",
+			instruction: "RUN --mount=type=tmpfs,target=/tmp,id=foo ls",
+			snippet:     "--mount=type=tmpfs,target=/tmp,id=foo",
+		},
+		{
+			desc:        "RUN mount without target",
+			instruction: "RUN --mount=type=cache ls",
+			snippet:     "--mount=type=cache",
+		},
+		{
+			desc:        "RUN invalid cache sharing",
+			instruction: "RUN --mount=type=cache,target=/c,sharing=everyone ls",
+			snippet:     "--mount=type=cache,target=/c,sharing=everyone",
+		},
+		{
+			desc:        "RUN invalid network",
+			instruction: "RUN --network=bridge ls",
+			snippet:     "--network=bridge",
+		},
+		{
+			desc:        "HEALTHCHECK invalid duration",
+			instruction: "HEALTHCHECK --interval=5 CMD true",
+			snippet:     "--interval=5",
+		},
+		{
+			desc:        "HEALTHCHECK duration too short",
+			instruction: "HEALTHCHECK --timeout=10us CMD true",
+			snippet:     "--timeout=10us",
+		},
+		{
+			desc:        "HEALTHCHECK negative retries",
+			instruction: "HEALTHCHECK --retries=-1 CMD true",
+			snippet:     "--retries=-1",
+		},
+		{
+			desc:        "HEALTHCHECK unknown flag",
+			instruction: "HEALTHCHECK --foo CMD true",
+			snippet:     "--foo",
+			message:     `unknown HEALTHCHECK flag: "foo"`,
+		},
+		{
+			desc:        "HEALTHCHECK flag without value",
+			instruction: "HEALTHCHECK --interval CMD true",
+			snippet:     "--interval",
+			message:     "requires a value",
+		},
+		{
+			desc:        "HEALTHCHECK without CMD",
+			instruction: "HEALTHCHECK curl -f http://localhost/",
+			snippet:     "curl -f http://localhost/",
+		},
+		{
+			desc:        "HEALTHCHECK NONE with arguments",
+			instruction: "HEALTHCHECK NONE please",
+			snippet:     "NONE please",
+		},
+		{
+			desc:        "LABEL missing value",
+			instruction: "LABEL key",
+			snippet:     "key",
+		},
+		{
+			desc:        "LABEL unterminated quote",
+			instruction: `LABEL a=b c="d`,
+			snippet:     `c="d`,
+		},
+		{
+			desc:        "EXPOSE invalid port",
+			instruction: "EXPOSE 80 http",
+			snippet:     "http",
+		},
+		{
+			desc:        "EXPOSE port out of range",
+			instruction: "EXPOSE 65536",
+			snippet:     "65536",
+		},
+		{
+			desc:        "EXPOSE port zero",
+			instruction: "EXPOSE 0/udp",
+			snippet:     "0/udp",
+		},
+		{
+			desc:        "EXPOSE inverted range",
+			instruction: "EXPOSE 9000-8000",
+			snippet:     "9000-8000",
+		},
+		{
+			desc:        "EXPOSE unknown protocol",
+			instruction: "EXPOSE 53/icmp",
+			snippet:     "53/icmp",
+		},
+		{
+			desc:        "EXPOSE host port",
+			instruction: "EXPOSE 8080:80",
+			snippet:     "8080:80",
+		},
+		{
+			desc:        "USER multiple arguments",
+			instruction: "USER app admin",
+			snippet:     "app admin",
+		},
+		{
+			desc:        "USER empty group",
+			instruction: "USER app:",
+			snippet:     "app:",
+		},
+		{
+			desc:        "STOPSIGNAL unknown name",
+			instruction: "STOPSIGNAL SIGFOO",
+			snippet:     "SIGFOO",
+		},
+		{
+			desc:        "STOPSIGNAL zero",
+			instruction: "STOPSIGNAL 0",
+			snippet:     "0",
+		},
+		{
+			desc:        "VOLUME empty path",
+			instruction: `VOLUME ["/data", ""]`,
+			snippet:     `["/data", ""]`,
+		},
+		{
+			desc:        "SHELL shell form",
+			instruction: "SHELL /bin/bash -c",
+			snippet:     "/bin/bash -c",
+		},
+		{
+			desc:        "SHELL empty",
+			instruction: "SHELL []",
+			snippet:     "[]",
+		},
+		{
+			desc:        "ARG unterminated quote",
+			instruction: `ARG A=1 B="x y`,
+			snippet:     `B="x y`,
+		},
+		{
+			desc:        "FROM unknown flag",
+			instruction: "FROM --arch=arm64 image",
+			snippet:     "--arch=arm64",
+		},
+		{
+			desc:        "FROM dangling AS",
+			instruction: "FROM image AS",
+			snippet:     "image AS",
+		},
+		{
+			desc:        "CMD flag",
+			instruction: "CMD --verbose echo",
+			snippet:     "--verbose",
+		},
+		{
+			desc:        "ENV flag",
+			instruction: "ENV --from=build A=b",
+			snippet:     "--from=build",
+		},
+		{
+			desc:        "ADD unknown flag",
+			instruction: "ADD --parents . /app",
+			snippet:     "--parents",
+		},
+		{
+			desc:        "COPY unterminated flag quote",
+			instruction: `COPY --chown="app . /app`,
+			snippet:     `--chown="app . /app`,
+		},
+		{
+			desc:        "COPY unterminated quote",
+			instruction: `COPY "my file /dst/`,
+			snippet:     `"my file /dst/`,
+		},
+		{
+			desc:        "ADD unterminated quote",
+			instruction: `ADD --link a 'b /dst/`,
+			snippet:     `a 'b /dst/`,
+		},
+		{
+			desc:        "RUN flag without name",
+			instruction: "RUN --=host make",
+			snippet:     "--=host",
+		},
+		{
+			desc:        "COPY repeated from",
+			instruction: "COPY --from=a --from=b /src /dst",
+			snippet:     "--from=b",
+		},
+		{
+			desc:        "ADD repeated chown",
+			instruction: "ADD --chown=a --link --chown=b /src /dst",
+			snippet:     "--chown=b",
+		},
+		{
+			desc:        "RUN repeated network",
+			instruction: "RUN --network=none --mount=type=tmpfs,target=/tmp --network=host make",
+			snippet:     "--network=host",
+		},
+		{
+			desc:        "FROM repeated platform",
+			instruction: "FROM --platform=linux/amd64 --platform=linux/arm64 image",
+			snippet:     "--platform=linux/arm64",
+		},
+		{
+			desc:        "HEALTHCHECK repeated retries",
+			instruction: "HEALTHCHECK --retries=1 --retries=2 CMD true",
+			snippet:     "--retries=2",
+		},
+		{
+			desc:        "ONBUILD ONBUILD",
+			instruction: "ONBUILD onbuild RUN make",
+			snippet:     "onbuild RUN make",
+		},
+		{
+			desc:        "ONBUILD FROM",
+			instruction: "ONBUILD FROM image",
+			snippet:     "FROM image",
+		},
+		{
+			desc:        "ONBUILD MAINTAINER",
+			instruction: "ONBUILD MAINTAINER me",
+			snippet:     "MAINTAINER me",
+		},
+		{
+			desc:        "ONBUILD invalid trigger",
+			instruction: "ONBUILD COPY --link=maybe . /app",
+			snippet:     "--link=maybe",
+		},
+		{
+			desc:        "ONBUILD without trigger",
+			instruction: "ONBUILD \\\n",
+			snippet:     "ONBUILD \\",
+		},
+		{
+			desc:        "ONBUILD end of flags without trigger",
+			instruction: "ONBUILD --\n",
+			snippet:     "ONBUILD --",
+		},
+		{
+			desc:        "ONBUILD end of flags continued without trigger",
+			instruction: "ONBUILD -- \\\n\n",
+			snippet:     "ONBUILD -- \\",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse(strings.NewReader("FROM image\n" + tc.instruction))
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse() error = %v, want a *ParseError", err)
+			}
+			if pe.Code != InvalidArgumentsErrorCode || pe.Line != 2 || pe.Snippet != tc.snippet {
+				t.Errorf("Parse() error = %#v, want code %q on line 2 with snippet %q", pe, InvalidArgumentsErrorCode, tc.snippet)
+			}
+			if !strings.Contains(pe.Error(), tc.message) {
+				t.Errorf("Parse() error = %v, want a message containing %q", pe, tc.message)
+			}
+		})
+	}
+}
+
+// ignoreSourceFields ignores the fields of statements which record where and how they were written in the original Dockerfile.
+var ignoreSourceFields = cmp.FilterPath(func(p cmp.Path) bool {
+	switch p.Last().String() {
+	case ".Node", ".Lines", ".FlagOrder", ".OptionOrder":
+		return true
+	}
+	return false
+}, cmp.Ignore())
+
+func TestWorkdirIsAbs(t *testing.T) {
+	for path, expected := range map[string]bool{
+		"/app":        true,
+		`C:\app`:      true,
+		"c:/app":      true,
+		`\app`:        true,
+		"app":         false,
+		"./app":       false,
+		"$HOME/app":   false,
+		"C:app":       false,
+		"1:/not/disk": false,
+	} {
+		inst := &statement.WorkdirInstruction{Path: path}
+		if got := inst.IsAbs(); got != expected {
+			t.Errorf("WorkdirInstruction{Path: %q}.IsAbs() = %v, want %v", path, got, expected)
+		}
+	}
+}
+
+func TestParseQuotedWorkdirAndUser(t *testing.T) {
+	parsed, err := Parse(strings.NewReader("# escape=`\n" +
+		"FROM image\n" +
+		"WORKDIR \"/my app\"\n" +
+		"USER \"root\"\n" +
+		"WORKDIR 'C:\\Program Files'\n"))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	if workdir := parsed.Statements[1].(*statement.WorkdirInstruction); !workdir.IsAbs() {
+		t.Errorf("WORKDIR %s: IsAbs() = false, want true", workdir.RawPath)
+	}
+	if user := parsed.Statements[2].(*statement.UserInstruction); !user.IsRoot() {
+		t.Errorf("USER %s: IsRoot() = false, want true", user.RawUser)
+	}
+	if workdir := parsed.Statements[3].(*statement.WorkdirInstruction); !workdir.IsAbs() || workdir.Path != `C:\Program Files` {
+		t.Errorf("WORKDIR %s: Path = %q, IsAbs() = %v, want %q, true", workdir.RawPath, workdir.Path, workdir.IsAbs(), `C:\Program Files`)
+	}
+}
+
+func TestInstructionFlags(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		instruction string
+		expected    []statement.Flag
+	}{
+		{
+			desc:        "no flags",
+			instruction: "RUN make",
+		},
+		{
+			desc:        "repeated flags in order",
+			instruction: "RUN --network=none --mount=type=secret,id=b --mount=type=cache,target=/a make",
+			expected: []statement.Flag{
+				{Name: "network", Value: "none"},
+				{Name: "mount", Value: "type=secret,id=b"},
+				{Name: "mount", Value: "type=cache,target=/a"},
+			},
+		},
+		{
+			desc:        "mount options in order",
+			instruction: "RUN --mount=from=cache,target=/root/.cache,type=cache --mount=dst=/src,source=. make",
+			expected: []statement.Flag{
+				{Name: "mount", Value: "from=cache,target=/root/.cache,type=cache"},
+				{Name: "mount", Value: "type=bind,target=/src,source=."},
+			},
+		},
+		{
+			desc:        "secret mount source",
+			instruction: "RUN --mount=type=secret,id=aws,src=/run/aws.creds make",
+			expected: []statement.Flag{
+				{Name: "mount", Value: "type=secret,id=aws,source=/run/aws.creds"},
+			},
+		},
+		{
+			desc:        "boolean flags",
+			instruction: "COPY --link --from=build --parents=true /src /dst",
+			expected: []statement.Flag{
+				{Name: "link", IsBool: true},
+				{Name: "from", Value: "build"},
+				{Name: "parents", IsBool: true},
+			},
+		},
+		{
+			desc:        "interleaved repeated flags",
+			instruction: "ADD --exclude=*.md --chmod=644 --exclude=*.txt --link src /dst",
+			expected: []statement.Flag{
+				{Name: "exclude", Value: "*.md"},
+				{Name: "chmod", Value: "644"},
+				{Name: "exclude", Value: "*.txt"},
+				{Name: "link", IsBool: true},
+			},
+		},
+		{
+			desc:        "HEALTHCHECK flags",
+			instruction: "HEALTHCHECK --retries=3 --interval=5s CMD true",
+			expected: []statement.Flag{
+				{Name: "retries", Value: "3"},
+				{Name: "interval", Value: "5s"},
+			},
+		},
+		{
+			desc:        "FROM platform",
+			instruction: "FROM --platform=$BUILDPLATFORM golang",
+			expected:    []statement.Flag{{Name: "platform", Value: "$BUILDPLATFORM"}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			parsed, err := Parse(strings.NewReader("FROM image\n" + tc.instruction))
+			if err != nil {
+				t.Fatalf("Parse() error'd: %v", err)
+			}
+			inst := parsed.Statements[len(parsed.Statements)-1].(statement.Instruction)
+			if diff := cmp.Diff(tc.expected, inst.Flags()); diff != "" {
+				t.Error("mismatch (-want +got):\n", diff)
+			}
+		})
+	}
+}
+
+func TestParseImageReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	testCases := []struct {
+		ref        string
+		expected   statement.ImageReference
+		normalized string
+	}{
+		{
+			ref:        "golang",
+			expected:   statement.ImageReference{Repository: "golang"},
+			normalized: "docker.io/library/golang:latest",
+		},
+		{
+			ref:        "golang:1.21-alpine",
+			expected:   statement.ImageReference{Repository: "golang", Tag: "1.21-alpine"},
+			normalized: "docker.io/library/golang:1.21-alpine",
+		},
+		{
+			ref:        "org/app@" + digest,
+			expected:   statement.ImageReference{Repository: "org/app", Digest: digest},
+			normalized: "docker.io/org/app@" + digest,
+		},
+		{
+			ref:        "index.docker.io/golang",
+			expected:   statement.ImageReference{Registry: "index.docker.io", Repository: "golang"},
+			normalized: "docker.io/library/golang:latest",
+		},
+		{
+			ref:        "ghcr.io/org/team/app:v1@" + digest,
+			expected:   statement.ImageReference{Registry: "ghcr.io", Repository: "org/team/app", Tag: "v1", Digest: digest},
+			normalized: "ghcr.io/org/team/app:v1@" + digest,
+		},
+		{
+			ref:        "localhost:5000/app",
+			expected:   statement.ImageReference{Registry: "localhost:5000", Repository: "app"},
+			normalized: "localhost:5000/app:latest",
+		},
+		{
+			ref:        "localhost/app",
+			expected:   statement.ImageReference{Registry: "localhost", Repository: "app"},
+			normalized: "localhost/app:latest",
+		},
+		{
+			ref:        "[::1]:5000/my_app__x.y-z:TAG",
+			expected:   statement.ImageReference{Registry: "[::1]:5000", Repository: "my_app__x.y-z", Tag: "TAG"},
+			normalized: "[::1]:5000/my_app__x.y-z:TAG",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.ref, func(t *testing.T) {
+			t.Parallel()
+			ref, err := statement.ParseImageReference(tc.ref)
+			if err != nil {
+				t.Fatalf("ParseImageReference() error'd: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, ref); diff != "" {
+				t.Error("mismatch (-want +got):\n", diff)
+			}
+			if ref.String() != tc.ref {
+				t.Errorf("String() = %q, want %q", ref.String(), tc.ref)
+			}
+			if normalized := ref.Normalized().String(); normalized != tc.normalized {
+				t.Errorf("Normalized() = %q, want %q", normalized, tc.normalized)
+			}
+		})
+	}
+}
+
+func TestParseImageReferenceErrors(t *testing.T) {
+	for _, ref := range []string{
+		"",
+		"Golang",
+		"golang:",
+		"golang:${VERSION}",
+		"golang:-1",
+		"golang@sha256:abc",
+		"golang@" + strings.Repeat("a", 64),
+		"-bad.io/app",
+		"org//app",
+		"org/app_",
+		"app/",
+		"registry.io:port/app",
+		strings.Repeat("a", 256),
+	} {
+		if parsed, err := statement.ParseImageReference(ref); err == nil {
+			t.Errorf("ParseImageReference(%q) = %+v, want an error", ref, parsed)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package dockerfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Platform is the operating system and CPU architecture of an image, e.g. `linux/arm64/v8`.
+type Platform struct {
+	OS           string
+	Architecture string
+	// Variant is the variant of the CPU architecture, e.g. `v7` for `linux/arm/v7`, empty if there is none.
+	Variant string
+}
+
+// ParsePlatform parses a platform of the form `<os>/<arch>[/<variant>]`, as passed to `docker build --platform`.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(s), "/")
+	for _, part := range parts {
+		if part == "" {
+			return Platform{}, fmt.Errorf("invalid platform %q: must be of the form `<os>/<arch>[/<variant>]`", s)
+		}
+	}
+	switch len(parts) {
+	case 2:
+		return Platform{OS: parts[0], Architecture: parts[1]}, nil
+	case 3:
+		return Platform{OS: parts[0], Architecture: parts[1], Variant: parts[2]}, nil
+	}
+	return Platform{}, fmt.Errorf("invalid platform %q: must be of the form `<os>/<arch>[/<variant>]`", s)
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// args are the values of the predefined build arguments describing the platform, e.g. `TARGETARCH` for the prefix `TARGET`.
+func (p Platform) args(prefix string) map[string]string {
+	return map[string]string{
+		prefix + "PLATFORM": p.String(),
+		prefix + "OS":       p.OS,
+		prefix + "ARCH":     p.Architecture,
+		prefix + "VARIANT":  p.Variant,
+	}
+}
+
+// proxyArgs are the names of the predefined build arguments which configure proxies for RUN commands.
+// See: https://docs.docker.com/engine/reference/builder/#predefined-args
+var proxyArgs = []string{
+	"HTTP_PROXY", "http_proxy",
+	"HTTPS_PROXY", "https_proxy",
+	"FTP_PROXY", "ftp_proxy",
+	"NO_PROXY", "no_proxy",
+	"ALL_PROXY", "all_proxy",
+}
@@ -0,0 +1,33 @@
+package dockerfile
+
+import "testing"
+
+func TestParsePlatform(t *testing.T) {
+	testCases := []struct {
+		platform string
+		expected Platform
+	}{
+		{platform: "linux/amd64", expected: Platform{OS: "linux", Architecture: "amd64"}},
+		{platform: "linux/arm64/v8", expected: Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		{platform: "Windows/AMD64", expected: Platform{OS: "windows", Architecture: "amd64"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.platform, func(t *testing.T) {
+			got, err := ParsePlatform(tc.platform)
+			if err != nil {
+				t.Fatalf("ParsePlatform(%q) returned an unexpected error: %v", tc.platform, err)
+			}
+			if got != tc.expected {
+				t.Errorf("ParsePlatform(%q) = %+v, expected %+v", tc.platform, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParsePlatformErrors(t *testing.T) {
+	for _, platform := range []string{"", "linux", "linux/", "/amd64", "linux/arm/v7/extra"} {
+		if got, err := ParsePlatform(platform); err == nil {
+			t.Errorf("ParsePlatform(%q) = %+v, expected an error", platform, got)
+		}
+	}
+}
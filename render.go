@@ -20,54 +20,220 @@ var defaultRenderer = Renderer{escapeCharacter: DefaultExcapeCharacter}
 // 	return &Renderer{escapeCharacter: escapeCharacter, skipComments: skipComments}
 // }
 
+// Render writes the given Dockerfile to `out`.
+// If `df` was parsed losslessly, unmodified statements are reproduced exactly as they were written and only modified
+// or newly added statements are normalized. Otherwise, the whole Dockerfile is normalized.
 func (p Renderer) Render(df *Parsed, out io.Writer) error {
-	if df.EscapeCharacter != DefaultExcapeCharacter {
-		fmt.Fprintln(out, CommentToken, parser.EscapeParserDirectiveKey+"="+string(df.EscapeCharacter))
-		fmt.Fprintln(out)
+	if df.source != nil {
+		return p.renderLossless(df, out)
 	}
+	fmt.Fprint(out, renderHeader(df))
 	for i, stmt := range df.Statements {
 		if i > 0 {
 			// Avoid adding a newline at the end of the file.
 			fmt.Fprintln(out)
 		}
 		st := stmt.Type()
-		if cmnt, ok := stmt.(*statement.Comment); ok {
-			if i > 0 && df.Statements[i-1].Type() == statement.CommentType {
-				// Add a blank line between distinct comment blocks
-				fmt.Fprintln(out)
-			}
-			for j, line := range cmnt.Lines {
-				if j > 0 {
-					fmt.Fprintln(out)
-				}
-				fmt.Fprint(out, parser.CommentToken, line)
-			}
-		} else if inst, ok := stmt.(statement.Instruction); ok {
-			if st == statement.FROM && i > 0 && df.Statements[i-1].Type() != statement.CommentType {
-				// Add a blank line between FROM statement blocks
-				fmt.Fprintln(out)
+		if st == statement.CommentType && i > 0 && df.Statements[i-1].Type() == statement.CommentType {
+			// Add a blank line between distinct comment blocks
+			fmt.Fprintln(out)
+		}
+		if st == statement.FROM && i > 0 && df.Statements[i-1].Type() != statement.CommentType {
+			// Add a blank line between FROM statement blocks
+			fmt.Fprintln(out)
+		}
+		rendered, err := renderStatement(stmt, escapeCharacterOf(df))
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, rendered)
+	}
+	return nil
+}
+
+func (p Renderer) renderLossless(df *Parsed, out io.Writer) error {
+	w := &lineTrackingWriter{out: out, atLineStart: true, newline: df.source.newline}
+	if header := renderHeader(df); header == df.source.canonicalHeader {
+		w.WriteString(df.source.header)
+	} else {
+		w.WriteRendered(header)
+	}
+	for _, stmt := range df.Statements {
+		rendered, err := renderStatement(stmt, escapeCharacterOf(df))
+		if err != nil {
+			return err
+		}
+		orig, known := df.source.statements[stmt]
+		if !known {
+			// New statements are placed on their own line.
+			w.EnsureLineStart()
+			w.WriteRendered(rendered)
+			continue
+		}
+		w.EnsureLineStart()
+		w.WriteString(orig.leading)
+		if rendered == orig.canonical {
+			w.WriteString(orig.text)
+		} else {
+			w.WriteRendered(rendered)
+			w.WriteString(orig.lineEnding())
+		}
+	}
+	if df.source.trailer != "" {
+		w.EnsureLineStart()
+		w.WriteString(df.source.trailer)
+	}
+	if !w.atLineStart && df.source.finalNewline {
+		w.WriteString(w.newline)
+	}
+	return w.err
+}
+
+// lineTrackingWriter writes strings to `out`, keeping track of whether the output is at the start of a line.
+type lineTrackingWriter struct {
+	out         io.Writer
+	atLineStart bool
+	// newline is the line ending of the original Dockerfile, which new lines are terminated with.
+	newline string
+	err     error
+}
+
+func (w *lineTrackingWriter) WriteString(s string) {
+	if s == "" || w.err != nil {
+		return
+	}
+	_, w.err = io.WriteString(w.out, s)
+	w.atLineStart = strings.HasSuffix(s, "\n")
+}
+
+// WriteRendered writes the rendering of a statement, whose lines end with the line ending of the original Dockerfile.
+func (w *lineTrackingWriter) WriteRendered(s string) {
+	if w.newline != "\n" {
+		s = strings.ReplaceAll(s, "\n", w.newline)
+	}
+	w.WriteString(s)
+}
+
+// EnsureLineStart terminates the current line, if any.
+func (w *lineTrackingWriter) EnsureLineStart() {
+	if !w.atLineStart {
+		w.WriteString(w.newline)
+	}
+}
+
+// escapeCharacterOf is the escape character the Dockerfile is rendered with.
+func escapeCharacterOf(df *Parsed) rune {
+	if df.EscapeCharacter == 0 {
+		return DefaultExcapeCharacter
+	}
+	return df.EscapeCharacter
+}
+
+// renderHeader renders the parser directives of the Dockerfile, followed by a blank line.
+func renderHeader(df *Parsed) string {
+	escapeCharacter := escapeCharacterOf(df)
+	sb := strings.Builder{}
+	escapeRendered := false
+	for _, d := range df.Directives {
+		val := d.Value
+		if d.Key == EscapeParserDirectiveKey {
+			val = string(escapeCharacter)
+			escapeRendered = true
+		}
+		sb.WriteString(CommentToken + " " + d.Key + "=" + val + "\n")
+	}
+	if !escapeRendered && escapeCharacter != DefaultExcapeCharacter {
+		sb.WriteString(CommentToken + " " + EscapeParserDirectiveKey + "=" + string(escapeCharacter) + "\n")
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// renderStatement renders the normalized form of a single statement, without a trailing newline, quoting with the
+// given escape character.
+func renderStatement(stmt statement.Statement, escapeCharacter rune) (string, error) {
+	sb := strings.Builder{}
+	if cmnt, ok := stmt.(*statement.Comment); ok {
+		for j, line := range cmnt.Lines {
+			if j > 0 {
+				sb.WriteString("\n")
 			}
-			fmt.Fprint(out, string(inst.Type()))
-			for k, v := range inst.Flags() {
-				fmt.Fprint(out, " --", k, "=", v)
+			sb.WriteString(parser.CommentToken + line)
+		}
+	} else if bad, ok := stmt.(*statement.BadStatement); ok {
+		sb.WriteString(strings.Join(bad.Lines, "\n"))
+	} else if hc, ok := stmt.(*statement.HealthcheckInstruction); ok {
+		sb.WriteString(string(hc.Type()))
+		for _, f := range hc.Flags() {
+			sb.WriteString(" " + f.Format(escapeCharacter))
+		}
+		if hc.None || hc.Cmd == nil {
+			sb.WriteString(" NONE")
+		} else {
+			cmd, err := renderStatement(hc.Cmd, escapeCharacter)
+			if err != nil {
+				return "", err
 			}
-			arguments := inst.Arguments()
-			if arguments.Execable {
-				fmt.Fprint(out, ` [ "`, strings.Join(arguments.List, `", "`), `" ]`)
-			} else {
-				for _, arg := range arguments.List {
-					fmt.Fprint(out, " ", arg)
-				}
+			sb.WriteString(" " + cmd)
+		}
+	} else if onbuild, ok := stmt.(*statement.OnBuildInstruction); ok {
+		if onbuild.Trigger == nil {
+			return "", fmt.Errorf("%s instruction has no trigger", onbuild.Type())
+		}
+		trigger, err := renderStatement(onbuild.Trigger, escapeCharacter)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(string(onbuild.Type()) + " " + trigger)
+	} else if inst, ok := stmt.(statement.Instruction); ok {
+		sb.WriteString(string(inst.Type()))
+		for _, f := range inst.Flags() {
+			sb.WriteString(" " + f.Format(escapeCharacter))
+		}
+		arguments := inst.Arguments()
+		if arguments.Execable {
+			sb.WriteString(` [ "` + strings.Join(arguments.List, `", "`) + `" ]`)
+		} else {
+			for _, arg := range formatArguments(inst, arguments, escapeCharacter) {
+				sb.WriteString(" " + arg)
 			}
+		}
+		for _, heredoc := range arguments.Heredocs {
+			sb.WriteString("\n" + heredoc.Body + heredoc.Name)
+		}
+	} else {
+		return "", fmt.Errorf("unknown statement type: %s", stmt.Type())
+	}
+	return sb.String(), nil
+}
 
-		} else {
-			return fmt.Errorf("unknown statement type: %s", stmt.Type())
+// formatArguments is the list of arguments of the instruction, re-quoting any which were unquoted by the parser
+// with the given escape character.
+func formatArguments(inst statement.Instruction, arguments statement.Arguments, escapeCharacter rune) []string {
+	switch inst := inst.(type) {
+	case *statement.LabelInstruction:
+		list := make([]string, 0, len(inst.Labels))
+		for _, l := range inst.Labels {
+			list = append(list, l.Format(escapeCharacter))
+		}
+		return list
+	case *statement.WorkdirInstruction:
+		return []string{inst.FormatPath(escapeCharacter)}
+	case *statement.UserInstruction:
+		return []string{inst.FormatUser(escapeCharacter)}
+	case *statement.ArgInstruction:
+		list := make([]string, 0, len(inst.Declarations))
+		for _, d := range inst.Declarations {
+			list = append(list, d.Format(escapeCharacter))
 		}
+		return list
 	}
-	return nil
+	return arguments.List
 }
 
-// Render parses the
+// Render renders the given Dockerfile using the default Renderer.
 func Render(df *Parsed, out io.Writer) error {
 	return defaultRenderer.Render(df, out)
 }
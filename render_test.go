@@ -9,7 +9,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/dekkagaijin/go-dockerfile/statement"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func mustOpen(t *testing.T, path string) io.ReadCloser {
@@ -73,3 +75,198 @@ func TestRenderE2E(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderLosslessRoundTrip(t *testing.T) {
+	var paths []string
+	for _, dir := range []string{"testdata/render", "testdata/resolve"} {
+		if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if !d.IsDir() && filepath.Ext(path) != ".rendered" && filepath.Ext(path) != ".resolved" {
+				paths = append(paths, path)
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("failed to load testdata: %v", err)
+		}
+	}
+	if len(paths) == 0 {
+		t.Fatal("failed to load testdata")
+	}
+
+	for _, path := range paths {
+		path := path
+		t.Run(path, func(t *testing.T) {
+			t.Parallel()
+			f := mustOpen(t, path)
+			defer f.Close()
+			original, err := ioutil.ReadAll(f)
+			if err != nil {
+				t.Fatalf("failed to read testdata file: %v", err)
+			}
+			assertLosslessRoundTrip(t, string(original))
+		})
+	}
+}
+
+func assertLosslessRoundTrip(t *testing.T, original string) {
+	t.Helper()
+	parsed, err := Parser{Lossless: true}.Parse(strings.NewReader(original))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	sb := strings.Builder{}
+	if err := Render(parsed, &sb); err != nil {
+		t.Fatalf("Render() error'd: %v", err)
+	}
+	if diff := cmp.Diff(original, sb.String()); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestRenderLosslessModified(t *testing.T) {
+	original := "# escape=`\r\n\r\n" +
+		"from  image\r\n" +
+		"run  make `\r\n" +
+		"  # interstitial comment\r\n" +
+		"\r\n" +
+		"    install\r\n" +
+		"\r\n" +
+		"  copy . /app\r\n" +
+		"cmd  [\"/app\"]"
+	assertLosslessRoundTrip(t, original)
+
+	parsed, err := Parser{Lossless: true}.Parse(strings.NewReader(original))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	parsed.Statements[0].(*statement.FromInstruction).Image = "other-image"
+	parsed.Statements = append(parsed.Statements[:2], parsed.Statements[3:]...)
+	parsed.Statements = append(parsed.Statements, &statement.GenericInstruction{
+		InstructionType: statement.USER,
+		Args:            statement.Arguments{List: []string{"nobody"}},
+	})
+
+	sb := strings.Builder{}
+	if err := Render(parsed, &sb); err != nil {
+		t.Fatalf("Render() error'd: %v", err)
+	}
+	expected := "# escape=`\r\n\r\n" +
+		"FROM other-image\r\n" +
+		"run  make `\r\n" +
+		"  # interstitial comment\r\n" +
+		"\r\n" +
+		"    install\r\n" +
+		"cmd  [\"/app\"]\r\n" +
+		"USER nobody"
+	if diff := cmp.Diff(expected, sb.String()); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestRenderLosslessCRLF(t *testing.T) {
+	original := "FROM image\r\n" +
+		"RUN make\r\n"
+	parsed, err := Parser{Lossless: true}.Parse(strings.NewReader(original))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	parsed.Directives = append(parsed.Directives, Directive{Key: "syntax", Value: "docker/dockerfile:1"})
+	parsed.Statements = []statement.Statement{
+		parsed.Statements[0],
+		&statement.RunInstruction{
+			Command:  "<<EOF sh",
+			Heredocs: []statement.Heredoc{{Name: "EOF", Body: "echo a\necho b\n"}},
+		},
+		parsed.Statements[1],
+		&statement.GenericInstruction{
+			InstructionType: statement.USER,
+			Args:            statement.Arguments{List: []string{"nobody"}},
+		},
+	}
+
+	sb := strings.Builder{}
+	if err := Render(parsed, &sb); err != nil {
+		t.Fatalf("Render() error'd: %v", err)
+	}
+	expected := "# syntax=docker/dockerfile:1\r\n\r\n" +
+		"FROM image\r\n" +
+		"RUN <<EOF sh\r\n" +
+		"echo a\r\n" +
+		"echo b\r\n" +
+		"EOF\r\n" +
+		"RUN make\r\n" +
+		"USER nobody\r\n"
+	if diff := cmp.Diff(expected, sb.String()); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestRenderWindowsEscape(t *testing.T) {
+	original := "# escape=`\n\n" +
+		"ARG DIR=C:\\bar\n" +
+		"FROM image\n" +
+		"LABEL path=C:\\foo quoted=\"C:\\bar baz\" \"say`\"hi`\"\"=x\n" +
+		"RUN --mount=type=bind,source=C:\\src,target=C:\\dst dir"
+	parsed, err := Parse(strings.NewReader(original))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	arg := parsed.Statements[0].(*statement.ArgInstruction)
+	if got, want := arg.Declarations[0].Default, `C:\bar`; got != want {
+		t.Errorf("ARG default = %q, want %q", got, want)
+	}
+	label := parsed.Statements[2].(*statement.LabelInstruction)
+	for key, want := range map[string]string{`path`: `C:\foo`, `quoted`: `C:\bar baz`, `say"hi"`: `x`} {
+		if got, _ := label.Get(key); got != want {
+			t.Errorf("label %q = %q, want %q", key, got, want)
+		}
+	}
+
+	arg.Declarations[0].Default = `C:\new bar`
+	label.Set("path", `C:\new path`)
+	sb := strings.Builder{}
+	if err := Render(parsed, &sb); err != nil {
+		t.Fatalf("Render() error'd: %v", err)
+	}
+	expected := "# escape=`\n\n" +
+		"ARG DIR=\"C:\\new bar\"\n\n" +
+		"FROM image\n" +
+		"LABEL path=\"C:\\new path\" quoted=\"C:\\bar baz\" \"say`\"hi`\"\"=x\n" +
+		"RUN --mount=type=bind,source=C:\\src,target=C:\\dst dir"
+	if diff := cmp.Diff(expected, sb.String()); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+
+	reparsed, err := Parse(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("Parse() of rendered Dockerfile error'd: %v", err)
+	}
+	if diff := cmp.Diff(arg, reparsed.Statements[0], ignoreSourceFields, cmpopts.IgnoreFields(statement.ArgDeclaration{}, "RawDefault")); diff != "" {
+		t.Error("ARG render->parse round trip mismatch (-want +got):\n", diff)
+	}
+	relabel := reparsed.Statements[2].(*statement.LabelInstruction)
+	for _, l := range label.Labels {
+		if got, _ := relabel.Get(l.Key); got != l.Value {
+			t.Errorf("label %q = %q after render->parse round trip, want %q", l.Key, got, l.Value)
+		}
+	}
+	if diff := cmp.Diff(parsed.Statements[3], reparsed.Statements[3], ignoreSourceFields); diff != "" {
+		t.Error("RUN render->parse round trip mismatch (-want +got):\n", diff)
+	}
+	rerendered := strings.Builder{}
+	if err := Render(reparsed, &rerendered); err != nil {
+		t.Fatalf("Render() of reparsed Dockerfile error'd: %v", err)
+	}
+	if diff := cmp.Diff(sb.String(), rerendered.String()); diff != "" {
+		t.Error("render->parse->render mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestRenderOnBuildWithoutTrigger(t *testing.T) {
+	parsed := &Parsed{Statements: []statement.Statement{
+		&statement.FromInstruction{Image: "image"},
+		&statement.OnBuildInstruction{},
+	}}
+	if err := Render(parsed, io.Discard); err == nil {
+		t.Error("Render() of ONBUILD without a trigger succeeded, want an error")
+	}
+}
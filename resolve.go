@@ -3,33 +3,77 @@ package dockerfile
 import (
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/dekkagaijin/go-dockerfile/internal/parser"
+	"github.com/dekkagaijin/go-dockerfile/shell"
 	"github.com/dekkagaijin/go-dockerfile/statement"
 )
 
 type scopedVars struct {
 	ARG, ENV map[string]string
+	// unknown are the variables whose values are not known when resolving partially,
+	// with the unresolved build arguments which they derive from.
+	unknown map[string][]string
+	// shell is set in the scope of RUN commands, whose references to variables which are not in scope,
+	// e.g. `$?`, loop variables, or variables set by the command itself, are left to the shell.
+	shell bool
+}
+
+// lookup looks up the value of a variable, an ENV taking precedence over an ARG of the same name.
+func (s scopedVars) lookup(name string) (string, bool) {
+	if val, isSet := s.ENV[name]; isSet {
+		return val, true
+	}
+	val, isSet := s.ARG[name]
+	return val, isSet
 }
 
 type buildStage []statement.Statement
 
 type resolver struct {
 	escapeCharacter rune
-	input, global   scopedVars
+	opts            ResolveOptions
+	global          scopedVars
+	// platformArgs are the values of the predefined build arguments describing the build and target platforms.
+	platformArgs map[string]string
+	// unknownPlatformArgs are the predefined build arguments describing platforms which were not given, whose values are not known.
+	unknownPlatformArgs map[string]bool
+
+	// partial keeps build arguments whose values are not known, and references to them, see ResolvePartial.
+	partial bool
+	// unresolved are the build arguments whose values are not known, in order of declaration.
+	unresolved []UnresolvedVariable
+	// references are the unresolved build arguments referenced while resolving the current statement.
+	references []string
 }
 
 func (r *resolver) Resolve(df *Parsed) (*Parsed, error) {
 	r.escapeCharacter = df.EscapeCharacter
 	r.global.ARG = map[string]string{}
+	r.global.unknown = map[string][]string{}
 	// r.global.ENV = map[string]string{} // no such thing as ENV outside of a build stage
+	r.platformArgs = map[string]string{}
+	r.unknownPlatformArgs = map[string]bool{}
+	buildPlatform, targetPlatform := r.opts.BuildPlatform, r.opts.TargetPlatform
+	if targetPlatform == (Platform{}) {
+		targetPlatform = buildPlatform
+	}
+	for prefix, platform := range map[string]Platform{"BUILD": buildPlatform, "TARGET": targetPlatform} {
+		for k, v := range platform.args(prefix) {
+			if platform == (Platform{}) {
+				r.unknownPlatformArgs[k] = true
+			} else {
+				r.platformArgs[k] = v
+			}
+		}
+	}
 
 	statements := df.Statements
 
 	resolved := Parsed{
 		EscapeCharacter: df.EscapeCharacter,
+		Directives:      append([]Directive(nil), df.Directives...),
 	}
 
 	// Consume the preamble before the first build stage...
@@ -37,15 +81,24 @@ func (r *resolver) Resolve(df *Parsed) (*Parsed, error) {
 	for ; len(statements) > 0 && statements[0].Type() != statement.FROM; statements = statements[1:] {
 		stmt := statements[0]
 		if arg, isARGStatement := stmt.(*statement.ArgInstruction); isARGStatement {
-			var err error
-			cmnt, err := r.resolveArgInstruction(arg, r.global)
+			cmnt, kept, err := r.resolveArgInstruction(arg, r.global)
 			if err != nil {
 				return nil, err
 			}
-			if argTombstones == nil {
-				argTombstones = cmnt
-			} else {
-				argTombstones.Lines = append(argTombstones.Lines, cmnt.Lines...)
+			if cmnt != nil {
+				if argTombstones == nil {
+					argTombstones = cmnt
+				} else {
+					argTombstones.Lines = append(argTombstones.Lines, cmnt.Lines...)
+				}
+			}
+			if kept != nil {
+				if argTombstones != nil {
+					resolved.Statements = append(resolved.Statements, argTombstones)
+					argTombstones = nil
+				}
+				resolved.Statements = append(resolved.Statements, kept)
+				r.recordReferences(kept)
 			}
 			continue
 		}
@@ -71,187 +124,761 @@ func (r *resolver) Resolve(df *Parsed) (*Parsed, error) {
 		}
 		stages[len(stages)-1] = append(stages[len(stages)-1], stmt)
 	}
+	var failed []error
 	for _, stage := range stages {
 		resolvedStmts, err := r.resolveBuildStage(stage)
 		if err != nil {
-			return nil, err
+			if r.opts.Target == "" {
+				return nil, err
+			}
+			// The target may not depend on the stage, keep its FROM so that the remaining stages can be found.
+			resolvedStmts = stage[:1]
 		}
+		failed = append(failed, err)
 		resolved.Statements = append(resolved.Statements, resolvedStmts...)
 	}
+	if r.opts.Target != "" {
+		if err := pruneStages(&resolved, r.opts.Target, failed); err != nil {
+			return nil, err
+		}
+	}
 
 	return &resolved, nil
 }
 
+// pruneStages removes the stages which the target stage does not transitively depend on, like `docker build --target`.
+// failed are the failures to resolve each of the stages, which are only returned if the target depends on the stage.
+func pruneStages(df *Parsed, target string, failed []error) error {
+	stages := df.Stages()
+	var targetStage *Stage
+	for _, stage := range stages {
+		if strings.EqualFold(stage.From.Alias, target) {
+			targetStage = stage
+		}
+	}
+	if targetStage == nil {
+		return fmt.Errorf("target stage %q could not be found", target)
+	}
+
+	needed := map[int]bool{}
+	var need func(stage *Stage) error
+	need = func(stage *Stage) error {
+		if needed[stage.Index] {
+			return nil
+		}
+		if err := failed[stage.Index]; err != nil {
+			return err
+		}
+		needed[stage.Index] = true
+		for _, dep := range stage.Dependencies() {
+			if err := need(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := need(targetStage); err != nil {
+		return err
+	}
+
+	var pruned []statement.Statement
+	stageIndex := -1
+	for _, stmt := range df.Statements {
+		if stmt.Type() == statement.FROM {
+			stageIndex++
+		}
+		// Statements preceding the first stage, e.g. global ARGs, are always kept.
+		if stageIndex == -1 || needed[stageIndex] {
+			pruned = append(pruned, stmt)
+		}
+	}
+	df.Statements = pruned
+	return nil
+}
+
 func (r *resolver) resolveBuildStage(stage buildStage) ([]statement.Statement, error) {
 	local := scopedVars{
-		ARG: map[string]string{},
-		ENV: map[string]string{},
+		ARG:     map[string]string{},
+		ENV:     map[string]string{},
+		unknown: map[string][]string{},
 	}
 	var resolved []statement.Statement
-	for _, stmt := range stage {
-		stmt, err := r.resolveStatement(stmt, local)
+	for i, stmt := range stage {
+		arg, isARG := stmt.(*statement.ArgInstruction)
+		if !isARG {
+			resolvedStmt, err := r.resolveStatement(stmt, local)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, resolvedStmt)
+			r.recordReferences(resolvedStmt)
+			continue
+		}
+
+		cmnt, kept, err := r.resolveArgInstruction(arg, local)
 		if err != nil {
 			return nil, err
 		}
-		if stmt != nil {
-			resolved = append(resolved, stmt)
+		if cmnt != nil {
+			resolved = append(resolved, cmnt)
+		}
+		if kept != nil {
+			resolved = append(resolved, kept)
+			r.recordReferences(kept)
+		}
+		// Build arguments are in the environment of RUN commands, so must still be declared for any which are not expanded.
+		if !r.opts.ExpandRun && containsRun(stage[i+1:]) {
+			if pinned := pinArgInstruction(arg, local); len(pinned.Declarations) > 0 {
+				resolved = append(resolved, pinned)
+			}
 		}
 	}
 	return resolved, nil
 }
 
+func containsRun(stmts []statement.Statement) bool {
+	for _, stmt := range stmts {
+		if stmt.Type() == statement.RUN {
+			return true
+		}
+	}
+	return false
+}
+
+// pinArgInstruction re-declares the build arguments with their resolved values as defaults.
+// Build arguments whose values are not known are already declared as written.
+func pinArgInstruction(raw *statement.ArgInstruction, scope scopedVars) *statement.ArgInstruction {
+	pinned := &statement.ArgInstruction{}
+	for _, decl := range raw.Declarations {
+		val, isSet := scope.ARG[decl.Name]
+		if !isSet {
+			continue
+		}
+		pinned.Declarations = append(pinned.Declarations, statement.ArgDeclaration{
+			Name:       decl.Name,
+			HasDefault: true,
+			Default:    val,
+		})
+	}
+	return pinned
+}
+
+// recordReferences records the statement as using the unresolved build arguments referenced while resolving it.
+func (r *resolver) recordReferences(stmt statement.Statement) {
+	for _, name := range r.references {
+		if r.partial && r.unknownPlatformArgs[name] && !r.isUnresolved(name) {
+			// Predefined, so unresolved from its first reference rather than a declaration.
+			r.unresolved = append(r.unresolved, UnresolvedVariable{Name: name})
+		}
+		for i := range r.unresolved {
+			v := &r.unresolved[i]
+			if v.Name == name && (len(v.Statements) == 0 || v.Statements[len(v.Statements)-1] != stmt) {
+				v.Statements = append(v.Statements, stmt)
+			}
+		}
+	}
+	r.references = nil
+}
+
+// referencedSince are the distinct unresolved build arguments referenced since there were n references.
+func (r *resolver) referencedSince(n int) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range r.references[n:] {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resolveStatement expands variables where Docker would at build time, i.e. in the arguments of
+// ADD, COPY, ENV, EXPOSE, FROM, LABEL, STOPSIGNAL, USER, VOLUME and WORKDIR.
+// The commands of RUN, CMD, ENTRYPOINT and HEALTHCHECK are left to the shell, see ResolveOptions.ExpandRun.
+// ONBUILD triggers are kept as written, since they are run by downstream builds, in which the variables of the
+// current build stage are not in scope.
+//
+// See: https://docs.docker.com/engine/reference/builder/#environment-replacement
 func (r *resolver) resolveStatement(stmt statement.Statement, local scopedVars) (statement.Statement, error) {
 	switch inst := stmt.(type) {
 	case *statement.FromInstruction:
-		local.ARG = map[string]string{}
-		local.ENV = map[string]string{}
 		return r.resolveFromInstruction(inst)
-	case *statement.ArgInstruction:
-		return r.resolveArgInstruction(inst, local)
 	case *statement.EnvInstruction:
 		return r.resolveEnvInstruction(inst, local)
 	case *statement.AddInstruction:
-		return r.resolveAddInstruction(inst, local), nil
-	case *statement.GenericInstruction:
-		return r.resolveGenericInstruction(inst, local), nil
+		return r.resolveAddInstruction(inst, local)
+	case *statement.CopyInstruction:
+		return r.resolveCopyInstruction(inst, local)
+	case *statement.LabelInstruction:
+		return r.resolveLabelInstruction(inst, local)
+	case *statement.ExposeInstruction:
+		return r.resolveExposeInstruction(inst, local)
+	case *statement.UserInstruction:
+		return r.resolveUserInstruction(inst, local)
+	case *statement.WorkdirInstruction:
+		resolved := &statement.WorkdirInstruction{
+			Node:    inst.Node,
+			RawPath: inst.RawPath,
+			Lines:   r.expandLines(inst.Lines, local),
+		}
+		if err := r.expandFields(local, &resolved.RawPath); err != nil {
+			return nil, err
+		}
+		var err error
+		if resolved.Path, err = r.expandValue(inst.RawPath, local); err != nil {
+			return nil, err
+		}
+		return resolved, nil
+	case *statement.StopSignalInstruction:
+		resolved := &statement.StopSignalInstruction{
+			Node:   inst.Node,
+			Signal: inst.Signal,
+			Lines:  r.expandLines(inst.Lines, local),
+		}
+		if err := r.expandFields(local, &resolved.Signal); err != nil {
+			return nil, err
+		}
+		return resolved, nil
+	case *statement.VolumeInstruction:
+		paths, err := r.expandList(inst.Paths, local)
+		if err != nil {
+			return nil, err
+		}
+		return &statement.VolumeInstruction{
+			Node:     inst.Node,
+			Execable: inst.Execable,
+			Paths:    paths,
+			Lines:    r.expandLines(inst.Lines, local),
+		}, nil
+	case *statement.RunInstruction:
+		return r.resolveRunInstruction(inst, local)
 	}
 	return stmt, nil
 }
 
 func (r *resolver) resolveFromInstruction(raw *statement.FromInstruction) (*statement.FromInstruction, error) {
-	return &statement.FromInstruction{
-		Platform: substituteVars(raw.Platform, r.global.ARG),
-		Image:    substituteVars(raw.Image, r.global.ARG),
+	resolved := &statement.FromInstruction{
+		Node:     raw.Node,
+		Platform: raw.Platform,
+		Image:    raw.Image,
 		Alias:    raw.Alias,
-	}, nil
+	}
+	// Only global ARGs are in scope for FROM, along with the predefined platform ARGs, e.g. `FROM --platform=$BUILDPLATFORM`.
+	// Those of platforms which were not given are kept as written.
+	scope := scopedVars{
+		ARG:     make(map[string]string, len(r.platformArgs)+len(r.global.ARG)),
+		unknown: make(map[string][]string, len(r.unknownPlatformArgs)+len(r.global.unknown)),
+	}
+	for k, v := range r.platformArgs {
+		scope.ARG[k] = v
+	}
+	for k := range r.unknownPlatformArgs {
+		scope.unknown[k] = []string{k}
+	}
+	for k, v := range r.global.ARG {
+		scope.ARG[k] = v
+		delete(scope.unknown, k)
+	}
+	for k, v := range r.global.unknown {
+		scope.unknown[k] = v
+	}
+	if err := r.expandFields(scope, &resolved.Platform, &resolved.Image); err != nil {
+		return nil, err
+	}
+	return resolved, nil
 }
 
-func (r *resolver) resolveArgInstruction(arg *statement.ArgInstruction, scope scopedVars) (*statement.Comment, error) {
-	cmnt := &statement.Comment{}
-	originalStatement := "ARG " + arg.Name
-	if arg.DefaultVal != "" {
-		originalStatement += "=" + arg.DefaultVal
+// resolveArgInstruction resolves the declared build arguments, returning a comment explaining where their values came from,
+// and, when resolving partially, an ARG instruction keeping the declarations whose values are not known. Either may be nil.
+func (r *resolver) resolveArgInstruction(arg *statement.ArgInstruction, scope scopedVars) (*statement.Comment, *statement.ArgInstruction, error) {
+	cmnt := &statement.Comment{Node: arg.Node}
+	kept := &statement.ArgInstruction{Node: arg.Node}
+	for _, decl := range arg.Declarations {
+		line, err := r.resolveArgDeclaration(decl, scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		if line == "" {
+			kept.Declarations = append(kept.Declarations, decl)
+			continue
+		}
+		cmnt.Lines = append(cmnt.Lines, line)
+	}
+	switch {
+	case len(kept.Declarations) == 0:
+		kept = nil
+	case len(kept.Declarations) == len(arg.Declarations):
+		// Nothing was resolved, keep the instruction untouched.
+		return nil, arg, nil
+	}
+	return cmnt, kept, nil
+}
+
+// resolveArgDeclaration sets the value of the build argument in scope, returning a comment line explaining where it came from.
+// When resolving partially, the value may not be known, in which case the line is empty and the build argument is marked as unknown.
+func (r *resolver) resolveArgDeclaration(decl statement.ArgDeclaration, scope scopedVars) (string, error) {
+	originalStatement := "ARG " + decl.String()
+	delete(scope.unknown, decl.Name)
+	if val, global := r.global.ARG[decl.Name]; global {
+		scope.ARG[decl.Name] = val
+		return fmt.Sprintf(" `%s` was resolved to `%s=%s` from prior declaration.", originalStatement, decl.Name, val), nil
+	}
+	if roots, global := r.global.unknown[decl.Name]; global {
+		r.markUnknown(decl.Name, roots, scope)
+		return "", nil
 	}
-	if val, global := r.global.ARG[arg.Name]; global {
-		scope.ARG[arg.Name] = val
-		cmnt.Lines = []string{fmt.Sprintf(" `%s` was resolved to `%s=%s` from prior declaration.", originalStatement, arg.Name, val)}
-		return cmnt, nil
+	if val, provided := r.opts.BuildArgs[decl.Name]; provided {
+		scope.ARG[decl.Name] = val
+		return fmt.Sprintf(" `%s` was resolved to `%s=%s` from build argument.", originalStatement, decl.Name, val), nil
+	}
+	if val, predefined := r.platformArgs[decl.Name]; predefined {
+		scope.ARG[decl.Name] = val
+		platform := "build"
+		if strings.HasPrefix(decl.Name, "TARGET") {
+			platform = "target"
+		}
+		return fmt.Sprintf(" `%s` was resolved to `%s=%s` from %s platform.", originalStatement, decl.Name, val, platform), nil
 	}
-	if val, provided := r.input.ARG[arg.Name]; provided {
-		scope.ARG[arg.Name] = val
-		cmnt.Lines = []string{fmt.Sprintf(" `%s` was resolved to `%s=%s` from build argument.", originalStatement, arg.Name, val)}
-		return cmnt, nil
+	if r.unknownPlatformArgs[decl.Name] {
+		// The platform was not given, so the value is not known, even if there is a default.
+		r.markUnknown(decl.Name, []string{decl.Name}, scope)
+		return "", nil
 	}
-	if arg.DefaultVal != "" {
-		scope.ARG[arg.Name] = arg.DefaultVal
-		cmnt.Lines = []string{fmt.Sprintf(" `%s` was resolved to `%s=%s` from default value.", originalStatement, arg.Name, arg.DefaultVal)}
-		return cmnt, nil
+	if decl.HasDefault {
+		n := len(r.references)
+		val, err := r.expandValue(decl.RawDefault, scope)
+		if err != nil {
+			return "", err
+		}
+		if roots := r.referencedSince(n); len(roots) > 0 {
+			r.markUnknown(decl.Name, roots, scope)
+			return "", nil
+		}
+		scope.ARG[decl.Name] = val
+		return fmt.Sprintf(" `%s` was resolved to `%s=%s` from default value.", originalStatement, decl.Name, val), nil
 	}
-	return nil, fmt.Errorf("could not resolve `%s`, did not have a default or provided value", originalStatement)
+	if r.partial {
+		if !r.isUnresolved(decl.Name) {
+			r.unresolved = append(r.unresolved, UnresolvedVariable{Name: decl.Name})
+		}
+		r.markUnknown(decl.Name, []string{decl.Name}, scope)
+		return "", nil
+	}
+	return "", fmt.Errorf("could not resolve `%s`, did not have a default or provided value", originalStatement)
+}
+
+func (r *resolver) isUnresolved(name string) bool {
+	for _, v := range r.unresolved {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// markUnknown marks the variable as unknown in scope, deriving from the given unresolved build arguments,
+// and as referencing them, since its declaration is kept.
+func (r *resolver) markUnknown(name string, roots []string, scope scopedVars) {
+	delete(scope.ARG, name)
+	scope.unknown[name] = roots
+	r.references = append(r.references, roots...)
 }
 
 func (r *resolver) resolveEnvInstruction(raw *statement.EnvInstruction, scope scopedVars) (*statement.EnvInstruction, error) {
 	resolved := &statement.EnvInstruction{
+		Node:     raw.Node,
 		Env:      make(map[string]string, len(raw.Env)),
 		KeyOrder: make([]string, 0, len(raw.KeyOrder)),
 	}
 	for _, key := range raw.KeyOrder {
-		val := substituteVars(raw.Env[key], scope.ENV, scope.ARG)
-		val = parser.EnsureModernEnvVal(val, r.escapeCharacter)
+		n := len(r.references)
+		rawVal, err := r.expand(raw.Env[key], scope)
+		if err != nil {
+			return nil, err
+		}
+		val, err := r.expandValue(raw.Env[key], scope)
+		if err != nil {
+			return nil, err
+		}
 		resolved.KeyOrder = append(resolved.KeyOrder, key)
-		resolved.Env[key] = val
+		resolved.Env[key] = parser.EnsureModernEnvVal(rawVal, r.escapeCharacter)
+		if roots := r.referencedSince(n); len(roots) > 0 {
+			// The value is kept as written, so is not known either.
+			delete(scope.ENV, key)
+			scope.unknown[key] = roots
+			continue
+		}
 		scope.ENV[key] = val
+		delete(scope.unknown, key)
 	}
 	return resolved, nil
 }
 
-func (r *resolver) resolveGenericInstruction(raw *statement.GenericInstruction, scope scopedVars) *statement.GenericInstruction {
-	resolved := &statement.GenericInstruction{
-		InstructionType: raw.InstructionType,
+func (r *resolver) resolveAddInstruction(raw *statement.AddInstruction, scope scopedVars) (*statement.AddInstruction, error) {
+	resolved := &statement.AddInstruction{
+		Node:       raw.Node,
+		Chown:      raw.Chown,
+		Chmod:      raw.Chmod,
+		Checksum:   raw.Checksum,
+		KeepGitDir: raw.KeepGitDir,
+		Link:       raw.Link,
+		FlagOrder:  raw.FlagOrder,
 		Args: statement.Arguments{
 			Execable: raw.Args.Execable,
 		},
+		Lines: r.expandLines(raw.Lines, scope),
 	}
-	for _, arg := range raw.Args.List {
-		resolved.Args.List = append(resolved.Args.List, substituteVars(arg, scope.ENV, scope.ARG))
+	if err := r.expandFields(scope, &resolved.Chown, &resolved.Chmod, &resolved.Checksum); err != nil {
+		return nil, err
 	}
-	for _, line := range raw.Lines {
-		resolved.Lines = append(resolved.Lines, substituteVars(line, scope.ENV, scope.ARG))
+	var err error
+	if resolved.Exclude, err = r.expandList(raw.Exclude, scope); err != nil {
+		return nil, err
 	}
-	return resolved
+	if resolved.Args.List, err = r.expandList(raw.Args.List, scope); err != nil {
+		return nil, err
+	}
+	if resolved.Args.Heredocs, err = r.resolveHeredocs(raw.Args.Heredocs, scope); err != nil {
+		return nil, err
+	}
+	return resolved, nil
 }
 
-func (r *resolver) resolveAddInstruction(raw *statement.AddInstruction, scope scopedVars) *statement.AddInstruction {
-	resolved := &statement.AddInstruction{
-		Args: statement.Arguments{
-			Execable: raw.Args.Execable,
-		},
+func (r *resolver) resolveCopyInstruction(raw *statement.CopyInstruction, scope scopedVars) (*statement.CopyInstruction, error) {
+	resolved := &statement.CopyInstruction{
+		Node:      raw.Node,
+		From:      raw.From,
+		Chown:     raw.Chown,
+		Chmod:     raw.Chmod,
+		Link:      raw.Link,
+		Parents:   raw.Parents,
+		FlagOrder: raw.FlagOrder,
+		Dest:      raw.Dest,
+		Execable:  raw.Execable,
+		Lines:     r.expandLines(raw.Lines, scope),
+	}
+	if err := r.expandFields(scope, &resolved.From, &resolved.Chown, &resolved.Chmod, &resolved.Dest); err != nil {
+		return nil, err
+	}
+	var err error
+	if resolved.Exclude, err = r.expandList(raw.Exclude, scope); err != nil {
+		return nil, err
+	}
+	if resolved.Sources, err = r.expandList(raw.Sources, scope); err != nil {
+		return nil, err
 	}
-	for _, arg := range raw.Args.List {
-		resolved.Args.List = append(resolved.Args.List, substituteVars(arg, scope.ENV, scope.ARG))
+	if resolved.Heredocs, err = r.resolveHeredocs(raw.Heredocs, scope); err != nil {
+		return nil, err
 	}
-	for _, line := range raw.Lines {
-		resolved.Lines = append(resolved.Lines, substituteVars(line, scope.ENV, scope.ARG))
+	return resolved, nil
+}
+
+func (r *resolver) resolveLabelInstruction(raw *statement.LabelInstruction, scope scopedVars) (*statement.LabelInstruction, error) {
+	resolved := &statement.LabelInstruction{
+		Node:   raw.Node,
+		Labels: make([]statement.Label, 0, len(raw.Labels)),
+		Lines:  r.expandLines(raw.Lines, scope),
+	}
+	for _, l := range raw.Labels {
+		label := statement.Label{RawKey: l.RawKey, RawValue: l.RawValue}
+		if err := r.expandFields(scope, &label.RawKey, &label.RawValue); err != nil {
+			return nil, err
+		}
+		var err error
+		if label.Key, err = r.expandValue(l.RawKey, scope); err != nil {
+			return nil, err
+		}
+		if label.Value, err = r.expandValue(l.RawValue, scope); err != nil {
+			return nil, err
+		}
+		resolved.Labels = append(resolved.Labels, label)
 	}
-	return resolved
+	return resolved, nil
 }
 
-// `substituteVars` expands environment variables present in the given string.
-// Multiple environments may be passed, which will be searched in order of decreasing preference.
-//
-// Dockerfiles support several forms of variable replacement:
-//
-// a) `$<name>`
-// b) `${<name>}`
-// c) `${<name>:-<value>}`
-// d) `${<name>:+<value>}`
-//
-// `<value>` can be any string, including other env vars.
+func (r *resolver) resolveExposeInstruction(raw *statement.ExposeInstruction, scope scopedVars) (*statement.ExposeInstruction, error) {
+	resolved := &statement.ExposeInstruction{
+		Node:  raw.Node,
+		Lines: r.expandLines(raw.Lines, scope),
+	}
+	for _, spec := range raw.Ports {
+		if spec.Protocol != "" {
+			resolved.Ports = append(resolved.Ports, spec)
+			continue
+		}
+		// A single variable may expand to multiple ports, e.g. `EXPOSE $PORTS`
+		n := len(r.references)
+		rawSpecs, err := r.lexer(false, scope).ExpandWords(spec.Raw, scope.lookup)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve EXPOSE %q: %w", spec.Raw, err)
+		}
+		if len(r.references) > n {
+			// The ports are not known, keep the spec as written.
+			resolved.Ports = append(resolved.Ports, spec)
+			continue
+		}
+		for _, rawSpec := range rawSpecs {
+			resolvedSpec, err := parser.ParsePortSpec(rawSpec)
+			if err != nil {
+				return nil, fmt.Errorf("could not resolve EXPOSE %q: %w", spec.Raw, err)
+			}
+			resolved.Ports = append(resolved.Ports, resolvedSpec)
+		}
+	}
+	return resolved, nil
+}
+
+func (r *resolver) resolveUserInstruction(raw *statement.UserInstruction, scope scopedVars) (*statement.UserInstruction, error) {
+	resolved := &statement.UserInstruction{
+		Node:     raw.Node,
+		RawUser:  raw.RawUser,
+		RawGroup: raw.RawGroup,
+		Lines:    r.expandLines(raw.Lines, scope),
+	}
+	if err := r.expandFields(scope, &resolved.RawUser, &resolved.RawGroup); err != nil {
+		return nil, err
+	}
+	var err error
+	if resolved.User, err = r.expandValue(raw.RawUser, scope); err != nil {
+		return nil, err
+	}
+	if resolved.Group, err = r.expandValue(raw.RawGroup, scope); err != nil {
+		return nil, err
+	}
+	// A variable may expand to both a user and a group, e.g. `USER $UID_GID`
+	if resolved.Group == "" {
+		if i := strings.Index(resolved.User, ":"); i != -1 {
+			resolved.User, resolved.Group = resolved.User[:i], resolved.User[i+1:]
+		}
+		if i := strings.Index(resolved.RawUser, ":"); i != -1 {
+			resolved.RawUser, resolved.RawGroup = resolved.RawUser[:i], resolved.RawUser[i+1:]
+		}
+	}
+	return resolved, nil
+}
+
+func (r *resolver) resolveRunInstruction(raw *statement.RunInstruction, scope scopedVars) (*statement.RunInstruction, error) {
+	resolved := &statement.RunInstruction{
+		Node:      raw.Node,
+		Network:   raw.Network,
+		Security:  raw.Security,
+		FlagOrder: raw.FlagOrder,
+		Command:   raw.Command,
+		Exec:      raw.Exec,
+		Heredocs:  raw.Heredocs,
+		Lines:     raw.Lines,
+	}
+	if err := r.expandFields(scope, &resolved.Network, &resolved.Security); err != nil {
+		return nil, err
+	}
+	if r.opts.ExpandRun {
+		// The predefined proxy ARGs are in the environment of the command without being declared.
+		runScope := scopedVars{ARG: map[string]string{}, ENV: scope.ENV, unknown: scope.unknown, shell: true}
+		for _, name := range proxyArgs {
+			if val, provided := r.opts.BuildArgs[name]; provided {
+				runScope.ARG[name] = val
+			}
+		}
+		for k, v := range scope.ARG {
+			runScope.ARG[k] = v
+		}
+		// The exec form is never expanded, as there is no shell to do so.
+		if err := r.expandFields(runScope, &resolved.Command); err != nil {
+			return nil, err
+		}
+		heredocs, err := r.resolveHeredocs(raw.Heredocs, runScope)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Heredocs = heredocs
+		resolved.Lines = r.expandLines(raw.Lines, runScope)
+	}
+	for _, m := range raw.Mounts {
+		spec, err := r.expand(m.String(), scope)
+		if err != nil {
+			return nil, err
+		}
+		mount, err := parser.ParseMount(spec)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve `--mount=%s`: %w", m, err)
+		}
+		resolved.Mounts = append(resolved.Mounts, mount)
+	}
+	return resolved, nil
+}
+
+// resolveHeredocs expands variables in the bodies of heredocs whose delimiters were not quoted.
+func (r *resolver) resolveHeredocs(raw []statement.Heredoc, scope scopedVars) ([]statement.Heredoc, error) {
+	var resolved []statement.Heredoc
+	for _, heredoc := range raw {
+		if heredoc.Expand {
+			body, err := r.expand(heredoc.Body, scope)
+			if err != nil {
+				return nil, err
+			}
+			heredoc.Body = body
+		}
+		resolved = append(resolved, heredoc)
+	}
+	return resolved, nil
+}
+
+// lexer expands variables the way BuildKit does, honouring the escape character of the Dockerfile.
+// A raw lexer keeps quotes and escape characters as written, so that the expanded text may be rendered in their place.
 //
 // See: https://docs.docker.com/engine/reference/builder/#environment-replacement
-func substituteVars(s string, scopes ...map[string]string) string {
-	return os.Expand(s, func(ph string) string {
-		if split := strings.SplitN(ph, ":+", 2); len(split) > 1 {
-			key := split[0]
-			valIfSet := split[1]
-			valIfSet = substituteVars(valIfSet, scopes...)
-			if _, isSet := lookupEnvVar(key, scopes...); isSet {
-				return valIfSet
+// References to variables whose values are not known in scope, e.g. when resolving partially, are kept as written,
+// as are references to variables which are not in the scope of a RUN command.
+func (r *resolver) lexer(raw bool, scope scopedVars) *shell.Lexer {
+	l := &shell.Lexer{EscapeToken: r.escapeCharacter, RawQuotes: raw, RawEscapes: raw}
+	if len(scope.unknown) > 0 || scope.shell {
+		l.SkipVar = func(name string) bool {
+			if _, isSet := scope.ENV[name]; isSet {
+				return false
+			}
+			if roots, unknown := scope.unknown[name]; unknown {
+				r.references = append(r.references, roots...)
+				return true
 			}
-			return ""
+			_, isSet := scope.ARG[name]
+			return scope.shell && !isSet
 		}
+	}
+	return l
+}
 
-		key := ph
-		defaultVal := ""
+// expand expands the variables present in raw text, keeping its quotes and escape characters.
+func (r *resolver) expand(raw string, scope scopedVars) (string, error) {
+	expanded, err := r.lexer(true, scope).Expand(raw, scope.lookup)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %q: %w", raw, err)
+	}
+	return expanded, nil
+}
+
+// expandValue expands the variables present in raw text, removing its quotes and escape characters, e.g. for the value of a LABEL.
+func (r *resolver) expandValue(raw string, scope scopedVars) (string, error) {
+	expanded, err := r.lexer(false, scope).Expand(raw, scope.lookup)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %q: %w", raw, err)
+	}
+	return expanded, nil
+}
 
-		if split := strings.SplitN(ph, ":-", 2); len(split) > 1 {
-			key = split[0]
-			defaultVal = split[1]
-			defaultVal = substituteVars(defaultVal, scopes...)
+// expandFields expands the variables present in each of the fields, in place.
+func (r *resolver) expandFields(scope scopedVars, fields ...*string) error {
+	for _, field := range fields {
+		expanded, err := r.expand(*field, scope)
+		if err != nil {
+			return err
 		}
-		if val, isSet := lookupEnvVar(key, scopes...); isSet {
-			return val
+		*field = expanded
+	}
+	return nil
+}
+
+func (r *resolver) expandList(raw []string, scope scopedVars) ([]string, error) {
+	var expanded []string
+	for _, s := range raw {
+		s, err := r.expand(s, scope)
+		if err != nil {
+			return nil, err
 		}
-		return defaultVal
-	})
+		expanded = append(expanded, s)
+	}
+	return expanded, nil
 }
 
-func lookupEnvVar(key string, scopes ...map[string]string) (string, bool) {
-	for _, scope := range scopes {
-		if val, isSet := scope[key]; isSet {
-			return val, true
+// expandLines expands the variables present in the physical lines of a statement, which are only informative, on a best-effort basis:
+// lines which cannot be expanded, e.g. comments with unbalanced quotes, are kept as written.
+func (r *resolver) expandLines(lines []string, scope scopedVars) []string {
+	var expanded []string
+	for _, line := range lines {
+		if s, err := r.expand(line, scope); err == nil {
+			line = s
 		}
+		expanded = append(expanded, line)
 	}
-	return "", false
+	return expanded
+}
+
+// ResolveOptions configures ResolveWithOptions.
+type ResolveOptions struct {
+	// BuildArgs are the values of build arguments, as passed to `docker build --build-arg`.
+	// Like Docker, the predefined proxy build arguments, e.g. `HTTP_PROXY`, need not be declared to be used by RUN commands.
+	BuildArgs map[string]string
+	// Env is the environment of the build.
+	Env map[string]string
+	// ExpandRun also expands variables in the shell-form commands and here-documents of RUN instructions, for readability.
+	// Only references to variables in scope, i.e. declared by ARG or ENV, are expanded, others are left to the shell.
+	// Docker leaves all of them to the shell, so the resolved Dockerfile may no longer build the same image,
+	// e.g. if a command reassigns a variable which is in scope before referencing it.
+	ExpandRun bool
+
+	// BuildPlatform is the platform of the builder, which sets the predefined BUILDPLATFORM, BUILDOS, BUILDARCH
+	// and BUILDVARIANT build arguments.
+	BuildPlatform Platform
+	// TargetPlatform is the platform being built for, which sets the predefined TARGETPLATFORM, TARGETOS, TARGETARCH
+	// and TARGETVARIANT build arguments. It defaults to BuildPlatform.
+	//
+	// Like Docker, the predefined platform build arguments are in the global scope, e.g. for `FROM --platform=$BUILDPLATFORM`,
+	// but must be declared with ARG to be used within a stage.
+	// If neither platform is given, their build arguments are not known, and references to them are kept as written.
+	TargetPlatform Platform
+
+	// Target is the name of the stage to build, like `docker build --target`. If set, only the target stage and the
+	// stages it depends on are kept, see Stage.Dependencies, and the other stages need not resolve.
+	Target string
 }
 
 // Resolve resolves the given values in the Dockerfile.
 func Resolve(df *Parsed, buildArg, env map[string]string) (*Parsed, error) {
-	r := resolver{
-		input: scopedVars{
-			ARG: buildArg,
-			ENV: env,
-		},
+	return ResolveWithOptions(df, ResolveOptions{BuildArgs: buildArg, Env: env})
+}
+
+// UnresolvedVariable is a build argument whose value was not known to ResolvePartial.
+type UnresolvedVariable struct {
+	Name string
+	// Statements are the statements of the resolved Dockerfile which still declare or reference the build argument, in order.
+	// This includes references to variables derived from it, e.g. `ENV URL=https://$HOST/`, but not RUN commands,
+	// which are not expanded unless ResolveOptions.ExpandRun is set.
+	Statements []statement.Statement
+}
+
+// ResolvePartial resolves whatever it can of the Dockerfile. Unlike ResolveWithOptions, build arguments with neither
+// a default nor a provided value are not an error: their declarations, and references to them or to variables derived
+// from them, are kept as written. These build arguments are returned in order of declaration, or of first reference
+// for the predefined build arguments describing platforms which were not given.
+func ResolvePartial(df *Parsed, opts ResolveOptions) (*Parsed, []UnresolvedVariable, error) {
+	r := resolver{opts: opts, partial: true}
+	resolved, err := r.Resolve(df)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Statements of stages which were pruned, see ResolveOptions.Target, no longer use the build arguments.
+	kept := map[statement.Statement]bool{}
+	for _, stmt := range resolved.Statements {
+		kept[stmt] = true
 	}
+	var unresolved []UnresolvedVariable
+	for _, v := range r.unresolved {
+		var stmts []statement.Statement
+		for _, stmt := range v.Statements {
+			if kept[stmt] {
+				stmts = append(stmts, stmt)
+			}
+		}
+		if len(stmts) > 0 {
+			unresolved = append(unresolved, UnresolvedVariable{Name: v.Name, Statements: stmts})
+		}
+	}
+	return resolved, unresolved, nil
+}
+
+// ResolveWithOptions resolves the values of the build arguments in the Dockerfile, expanding variables
+// only where Docker would at build time, unless configured otherwise.
+func ResolveWithOptions(df *Parsed, opts ResolveOptions) (*Parsed, error) {
+	r := resolver{opts: opts}
 	return r.Resolve(df)
 }
@@ -2,6 +2,7 @@ package dockerfile
 
 import (
 	"io/ioutil"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -10,16 +11,16 @@ import (
 
 func TestResolveE2E(t *testing.T) {
 	testCases := []struct {
-		desc          string
-		originalPath  string
-		buildArg, env map[string]string
+		desc         string
+		originalPath string
+		opts         ResolveOptions
 
 		expectedPath string
 	}{
 		{
 			desc:         "gauntlet",
 			originalPath: "testdata/resolve/gauntlet/Dockerfile",
-			buildArg: map[string]string{
+			opts: ResolveOptions{BuildArgs: map[string]string{
 				"ARG1": "val1",
 				"ARG2": "val2",
 				"ARG3": "val3",
@@ -27,10 +28,89 @@ func TestResolveE2E(t *testing.T) {
 				"FOO":  "foo value",
 
 				"RUNTIME_IMAGE": "runtime-image@sha256:0bf474896363505e5ea5e5d6ace8ebfb13a760a409b1fb467d428fc716f9f284",
-			},
-			env:          map[string]string{},
+			}},
 			expectedPath: "testdata/resolve/gauntlet/Dockerfile.resolved",
 		},
+		{
+			desc:         "heredoc",
+			originalPath: "testdata/resolve/heredoc/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{}},
+			expectedPath: "testdata/resolve/heredoc/Dockerfile.resolved",
+		},
+		{
+			desc:         "arg",
+			originalPath: "testdata/resolve/arg/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"PROVIDED": "from the command line"}},
+			expectedPath: "testdata/resolve/arg/Dockerfile.resolved",
+		},
+		{
+			desc:         "runtime config",
+			originalPath: "testdata/resolve/runtime-config/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"UID_GID": "1000:1001"}},
+			expectedPath: "testdata/resolve/runtime-config/Dockerfile.resolved",
+		},
+		{
+			desc:         "expose",
+			originalPath: "testdata/resolve/expose/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"PORTS": "9000 9001/sctp"}},
+			expectedPath: "testdata/resolve/expose/Dockerfile.resolved",
+		},
+		{
+			desc:         "label",
+			originalPath: "testdata/resolve/label/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"VENDOR": "Example Corp"}},
+			expectedPath: "testdata/resolve/label/Dockerfile.resolved",
+		},
+		{
+			desc:         "expansion",
+			originalPath: "testdata/resolve/expansion/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"UID": "1000"}},
+			expectedPath: "testdata/resolve/expansion/Dockerfile.resolved",
+		},
+		{
+			desc:         "runtime expansion",
+			originalPath: "testdata/resolve/runtime-expansion/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"NAME": "world"}},
+			expectedPath: "testdata/resolve/runtime-expansion/Dockerfile.resolved",
+		},
+		{
+			desc:         "expand RUN",
+			originalPath: "testdata/resolve/runtime-expansion/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"NAME": "world"}, ExpandRun: true},
+			expectedPath: "testdata/resolve/runtime-expansion/Dockerfile.expand-run.resolved",
+		},
+		{
+			desc:         "expand RUN keeps shell variables",
+			originalPath: "testdata/resolve/run-shell-variables/Dockerfile",
+			opts:         ResolveOptions{BuildArgs: map[string]string{"NAME": "app"}, ExpandRun: true},
+			expectedPath: "testdata/resolve/run-shell-variables/Dockerfile.resolved",
+		},
+		{
+			desc:         "platform",
+			originalPath: "testdata/resolve/platform/Dockerfile",
+			opts: ResolveOptions{
+				BuildArgs:      map[string]string{"HTTP_PROXY": "http://proxy.example.com:3128"},
+				ExpandRun:      true,
+				BuildPlatform:  Platform{OS: "linux", Architecture: "amd64"},
+				TargetPlatform: Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			},
+			expectedPath: "testdata/resolve/platform/Dockerfile.resolved",
+		},
+		{
+			desc:         "no platform",
+			originalPath: "testdata/resolve/platform/Dockerfile",
+			opts: ResolveOptions{
+				BuildArgs: map[string]string{"HTTP_PROXY": "http://proxy.example.com:3128"},
+				ExpandRun: true,
+			},
+			expectedPath: "testdata/resolve/platform/Dockerfile.no-platform.resolved",
+		},
+		{
+			desc:         "target",
+			originalPath: "testdata/resolve/target/Dockerfile",
+			opts:         ResolveOptions{Target: "release"},
+			expectedPath: "testdata/resolve/target/Dockerfile.resolved",
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -51,7 +131,7 @@ func TestResolveE2E(t *testing.T) {
 				t.Fatalf("Parse() error'd: %v", err)
 			}
 
-			resolved, err := Resolve(parsed, tc.buildArg, tc.env)
+			resolved, err := ResolveWithOptions(parsed, tc.opts)
 			if err != nil {
 				t.Fatalf("Resolve() error'd: %v", err)
 			}
@@ -69,3 +149,193 @@ func TestResolveE2E(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveErrors(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		dockerfile string
+		buildArg   map[string]string
+		expected   string
+	}{
+		{
+			desc:       "required variable unset",
+			dockerfile: "FROM alpine\nCOPY app /opt/${VERSION:?required}/",
+			buildArg:   map[string]string{},
+			expected:   `could not resolve "/opt/${VERSION:?required}/": VERSION: required`,
+		},
+		{
+			desc:       "required variable empty",
+			dockerfile: "FROM alpine\nARG VERSION\nWORKDIR /src/${VERSION:?}",
+			buildArg:   map[string]string{"VERSION": ""},
+			expected:   `could not resolve "/src/${VERSION:?}": VERSION: is not allowed to be empty`,
+		},
+		{
+			desc:       "unsupported modifier",
+			dockerfile: "FROM alpine\nENV A=${B:=c}",
+			buildArg:   map[string]string{},
+			expected:   `could not resolve "${B:=c}": unsupported modifier ":=" in substitution: "${B:=c}"`,
+		},
+		{
+			desc:       "missing brace",
+			dockerfile: "FROM alpine\nLABEL a=${B",
+			buildArg:   map[string]string{},
+			expected:   "could not resolve \"${B\": missing `}` in substitution: \"${B\"",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			parsed, err := Parse(strings.NewReader(tc.dockerfile))
+			if err != nil {
+				t.Fatalf("Parse() error'd: %v", err)
+			}
+			_, err = Resolve(parsed, tc.buildArg, map[string]string{})
+			if err == nil {
+				t.Fatalf("Resolve() did not error, expected %q", tc.expected)
+			}
+			if err.Error() != tc.expected {
+				t.Errorf("Resolve() returned error %q, expected %q", err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestResolveTargetErrors(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM alpine AS base",
+		"FROM base AS test",
+		"ARG REQUIRED",
+		"FROM base AS release",
+	}, "\n")
+	testCases := []struct {
+		target   string
+		expected string
+	}{
+		{target: "release"},
+		{target: "test", expected: "could not resolve `ARG REQUIRED`, did not have a default or provided value"},
+		{target: "missing", expected: `target stage "missing" could not be found`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			parsed, err := Parse(strings.NewReader(dockerfile))
+			if err != nil {
+				t.Fatalf("Parse() error'd: %v", err)
+			}
+			_, err = ResolveWithOptions(parsed, ResolveOptions{Target: tc.target})
+			if tc.expected == "" {
+				// The failing stage is not needed to build the target.
+				if err != nil {
+					t.Errorf("ResolveWithOptions() error'd: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("ResolveWithOptions() returned error %v, expected %q", err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestResolvePartial(t *testing.T) {
+	originalFile := mustOpen(t, "testdata/resolve/partial/Dockerfile")
+	defer originalFile.Close()
+	expectedBytes, err := ioutil.ReadFile("testdata/resolve/partial/Dockerfile.resolved")
+	if err != nil {
+		t.Fatalf("failed to read expected testdata file: %v", err)
+	}
+
+	parsed, err := Parse(originalFile)
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	resolved, unresolved, err := ResolvePartial(parsed, ResolveOptions{})
+	if err != nil {
+		t.Fatalf("ResolvePartial() error'd: %v", err)
+	}
+
+	sb := strings.Builder{}
+	if err := Render(resolved, &sb); err != nil {
+		t.Fatalf("Render() error'd: %v", err)
+	}
+	if diff := cmp.Diff(string(expectedBytes), sb.String()); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+
+	// The lines of the original Dockerfile on which each unresolved variable is still declared or referenced.
+	got := map[string][]int{}
+	for _, v := range unresolved {
+		got[v.Name] = []int{}
+		for _, stmt := range v.Statements {
+			got[v.Name] = append(got[v.Name], stmt.Location().Start.Line)
+		}
+	}
+	expected := map[string][]int{
+		"REGISTRY": {1, 2, 3},
+		"API_HOST": {4, 5, 7},
+		"SECRET":   {12, 13},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Error("unresolved variables mismatch (-want +got):\n", diff)
+	}
+
+	if _, err := Resolve(parsed, map[string]string{}, map[string]string{}); err == nil {
+		t.Error("Resolve() of a Dockerfile with unresolved build arguments did not error")
+	}
+}
+
+func TestResolvePartialPlatform(t *testing.T) {
+	originalFile := mustOpen(t, "testdata/resolve/platform/Dockerfile")
+	defer originalFile.Close()
+	parsed, err := Parse(originalFile)
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	_, unresolved, err := ResolvePartial(parsed, ResolveOptions{ExpandRun: true})
+	if err != nil {
+		t.Fatalf("ResolvePartial() error'd: %v", err)
+	}
+
+	var got []string
+	for _, v := range unresolved {
+		var lines []string
+		for _, stmt := range v.Statements {
+			lines = append(lines, strconv.Itoa(stmt.Location().Start.Line))
+		}
+		got = append(got, v.Name+": "+strings.Join(lines, ","))
+	}
+	expected := []string{
+		"BUILDPLATFORM: 2",
+		"TARGETOS: 3,5",
+		"TARGETARCH: 3,5,10,11",
+		"TARGETVARIANT: 3,5",
+		"TARGETPLATFORM: 7",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Error("unresolved variables mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestResolvePartialTarget(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM alpine AS base",
+		"ARG UNUSED",
+		"WORKDIR /$UNUSED",
+		"FROM alpine AS release",
+		"ARG VERSION",
+		"LABEL version=$VERSION",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	_, unresolved, err := ResolvePartial(parsed, ResolveOptions{Target: "release"})
+	if err != nil {
+		t.Fatalf("ResolvePartial() error'd: %v", err)
+	}
+	var names []string
+	for _, v := range unresolved {
+		names = append(names, v.Name)
+	}
+	if diff := cmp.Diff([]string{"VERSION"}, names); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
@@ -0,0 +1,123 @@
+package dockerfile
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dekkagaijin/go-dockerfile/statement"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScannerLongLines(t *testing.T) {
+	longCommand := "echo " + strings.Repeat("x", 1<<20)
+	dockerfile := "FROM image\nRUN " + longCommand + " \\\n  && true\nLABEL a=b"
+
+	s := NewScanner(strings.NewReader(dockerfile))
+	var types []statement.Type
+	for s.Scan() {
+		types = append(types, s.Statement().Type())
+		if run, ok := s.Statement().(*statement.RunInstruction); ok && run.Command != longCommand+" && true" {
+			t.Errorf("RUN command has length %d, want %d", len(run.Command), len(longCommand+" && true"))
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if diff := cmp.Diff([]statement.Type{statement.FROM, statement.RUN, statement.LABEL}, types); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
+
+// failingReader returns its error once the underlying reader is exhausted.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestScannerStreams(t *testing.T) {
+	readErr := errors.New("connection reset")
+	s := NewScanner(failingReader{
+		r:   strings.NewReader("# syntax=docker/dockerfile:1\n\nFROM image\nRUN make \\\n  install\nRUN echo\nRUN"),
+		err: readErr,
+	})
+
+	var lines []int
+	for s.Scan() {
+		lines = append(lines, s.Statement().Location().Start.Line)
+	}
+	// `RUN echo` cannot be known to be complete, as reading the line following it fails.
+	if diff := cmp.Diff([]int{3, 4}, lines); diff != "" {
+		t.Error("statement lines mismatch (-want +got):\n", diff)
+	}
+	if err := s.Err(); !errors.Is(err, readErr) {
+		t.Errorf("Err() = %v, want %v", err, readErr)
+	}
+	if diff := cmp.Diff([]Directive{{Key: "syntax", Value: "docker/dockerfile:1"}}, s.Directives()); diff != "" {
+		t.Error("directives mismatch (-want +got):\n", diff)
+	}
+	if s.Scan() {
+		t.Error("Scan() after failure = true, want false")
+	}
+}
+
+func TestScannerMatchesParse(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"# escape=`",
+		"FROM image",
+		"# comment",
+		"# continued",
+		"RUN <<EOF",
+		"echo heredoc",
+		"EOF",
+		"COPY a `",
+		"",
+		"     # interstitial",
+		"     b /dst",
+		"",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+
+	s := NewScanner(strings.NewReader(dockerfile))
+	var scanned []statement.Statement
+	for s.Scan() {
+		scanned = append(scanned, s.Statement())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if diff := cmp.Diff(parsed.Statements, scanned); diff != "" {
+		t.Error("mismatch (-parsed +scanned):\n", diff)
+	}
+	if s.EscapeCharacter() != '`' {
+		t.Errorf("EscapeCharacter() = %q, want '`'", s.EscapeCharacter())
+	}
+}
+
+func TestScannerRecover(t *testing.T) {
+	s := NewScanner(strings.NewReader("FROM image\nFOO bar\nbaz\nRUN make"))
+	s.Recover = true
+	var types []statement.Type
+	for s.Scan() {
+		types = append(types, s.Statement().Type())
+	}
+	if diff := cmp.Diff([]statement.Type{statement.FROM, statement.BadType, statement.RUN}, types); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+	var errs ErrorList
+	if err := s.Err(); !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != UnknownInstructionErrorCode {
+		t.Errorf("Err() = %v, want an ErrorList of one unknown instruction", err)
+	}
+}
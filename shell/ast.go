@@ -0,0 +1,424 @@
+package shell
+
+// Pos is a byte offset within the source parsed by Parse.
+type Pos int
+
+// Node is a node of the syntax tree of a shell script.
+type Node interface {
+	// Pos is the offset of the first byte of the node.
+	Pos() Pos
+	// End is the offset immediately after the last byte of the node.
+	End() Pos
+}
+
+// Script is a parsed shell script, i.e. a list of statements.
+type Script struct {
+	Stmts []*Stmt
+}
+
+func (s *Script) Pos() Pos { return stmtsPos(s.Stmts) }
+func (s *Script) End() Pos { return stmtsEnd(s.Stmts) }
+
+// Stmt is a command of a list, e.g. `make &` in `make & wait`.
+type Stmt struct {
+	Cmd Command
+	// Background is whether the command is run asynchronously, i.e. followed by `&`.
+	Background bool
+}
+
+func (s *Stmt) Pos() Pos { return s.Cmd.Pos() }
+func (s *Stmt) End() Pos { return s.Cmd.End() }
+
+// Command is one of *CallExpr, *BinaryCmd, *Pipeline, *Subshell, *Block, *IfClause, *WhileClause, *ForClause or *CaseClause.
+type Command interface {
+	Node
+	commandNode()
+}
+
+func (*CallExpr) commandNode()    {}
+func (*BinaryCmd) commandNode()   {}
+func (*Pipeline) commandNode()    {}
+func (*Subshell) commandNode()    {}
+func (*Block) commandNode()       {}
+func (*IfClause) commandNode()    {}
+func (*WhileClause) commandNode() {}
+func (*ForClause) commandNode()   {}
+func (*CaseClause) commandNode()  {}
+
+// CallExpr is a simple command, e.g. `DEBIAN_FRONTEND=noninteractive apt-get install -y curl >/dev/null`.
+type CallExpr struct {
+	// Assigns are the variable assignments preceding the command.
+	Assigns []*Assign
+	// Args are the command name and its arguments, empty if the command only assigns variables.
+	Args []*Word
+	// Redirs are the redirections of the command, in order of appearance.
+	Redirs []*Redirect
+}
+
+func (c *CallExpr) Pos() Pos {
+	pos := Pos(-1)
+	for _, n := range c.nodes() {
+		if pos == -1 || n.Pos() < pos {
+			pos = n.Pos()
+		}
+	}
+	return pos
+}
+
+func (c *CallExpr) End() Pos {
+	end := Pos(-1)
+	for _, n := range c.nodes() {
+		if n.End() > end {
+			end = n.End()
+		}
+	}
+	return end
+}
+
+func (c *CallExpr) nodes() []Node {
+	nodes := make([]Node, 0, len(c.Assigns)+len(c.Args)+len(c.Redirs))
+	for _, a := range c.Assigns {
+		nodes = append(nodes, a)
+	}
+	for _, w := range c.Args {
+		nodes = append(nodes, w)
+	}
+	for _, r := range c.Redirs {
+		nodes = append(nodes, r)
+	}
+	return nodes
+}
+
+// Name is the literal name of the command, e.g. `apt-get`, and whether it has one.
+// Commands which only assign variables, or whose name contains expansions, have none.
+func (c *CallExpr) Name() (string, bool) {
+	if len(c.Args) == 0 {
+		return "", false
+	}
+	return c.Args[0].Lit()
+}
+
+// BinaryOperator is the operator of a BinaryCmd.
+type BinaryOperator string
+
+const (
+	AndOperator BinaryOperator = "&&"
+	OrOperator  BinaryOperator = "||"
+)
+
+// BinaryCmd is an AND or OR list of two commands, e.g. `apt-get update && apt-get install -y curl`.
+// Lists of several commands nest to the left, i.e. `a && b || c` is `(a && b) || c`.
+type BinaryCmd struct {
+	OpPos Pos
+	Op    BinaryOperator
+	X, Y  Command
+}
+
+func (b *BinaryCmd) Pos() Pos { return b.X.Pos() }
+func (b *BinaryCmd) End() Pos { return b.Y.End() }
+
+// Pipeline is a sequence of commands connected by pipes, e.g. `! make | tee log`.
+type Pipeline struct {
+	// Bang is the position of the leading `!`, or -1 if the pipeline is not negated.
+	Bang Pos
+	// Cmds are the commands of the pipeline, at least one.
+	Cmds []Command
+}
+
+// Negated is whether the exit status of the pipeline is inverted with `!`.
+func (p *Pipeline) Negated() bool {
+	return p.Bang >= 0
+}
+
+func (p *Pipeline) Pos() Pos {
+	if p.Negated() {
+		return p.Bang
+	}
+	return p.Cmds[0].Pos()
+}
+
+func (p *Pipeline) End() Pos { return p.Cmds[len(p.Cmds)-1].End() }
+
+// Subshell is a list of statements run in a subshell, e.g. `(cd /src && make)`.
+type Subshell struct {
+	Lparen, Rparen Pos
+	Stmts          []*Stmt
+	Redirs         []*Redirect
+}
+
+func (s *Subshell) Pos() Pos { return s.Lparen }
+func (s *Subshell) End() Pos { return redirsEnd(s.Redirs, s.Rparen+1) }
+
+// Block is a list of statements grouped in braces, e.g. `{ echo a; echo b; } > log`.
+type Block struct {
+	Lbrace, Rbrace Pos
+	Stmts          []*Stmt
+	Redirs         []*Redirect
+}
+
+func (b *Block) Pos() Pos { return b.Lbrace }
+func (b *Block) End() Pos { return redirsEnd(b.Redirs, b.Rbrace+1) }
+
+// IfClause is a conditional, e.g. `if [ -f x ]; then rm x; else touch x; fi`.
+// `elif` branches are nested IfClauses in Else.
+type IfClause struct {
+	// Position is the position of the `if` or `elif` keyword.
+	Position Pos
+	Cond     []*Stmt
+	Then     []*Stmt
+	// Else is the statements of the `else` branch, or a single nested *IfClause for an `elif` branch.
+	Else []*Stmt
+	// Fi is the position of the `fi` keyword, shared by any nested `elif` branches.
+	Fi     Pos
+	Redirs []*Redirect
+}
+
+func (c *IfClause) Pos() Pos { return c.Position }
+func (c *IfClause) End() Pos { return redirsEnd(c.Redirs, c.Fi+2) }
+
+// WhileClause is a `while` or `until` loop, e.g. `while read line; do echo "$line"; done < file`.
+type WhileClause struct {
+	Position Pos
+	Until    bool
+	Cond     []*Stmt
+	Do       []*Stmt
+	Done     Pos
+	Redirs   []*Redirect
+}
+
+func (c *WhileClause) Pos() Pos { return c.Position }
+func (c *WhileClause) End() Pos { return redirsEnd(c.Redirs, c.Done+4) }
+
+// ForClause is a `for` loop, e.g. `for f in *.txt; do cat "$f"; done`.
+type ForClause struct {
+	Position Pos
+	Name     *Lit
+	// Items are the words iterated over, nil if there is no `in`, i.e. the positional parameters are iterated over.
+	Items  []*Word
+	Do     []*Stmt
+	Done   Pos
+	Redirs []*Redirect
+}
+
+func (c *ForClause) Pos() Pos { return c.Position }
+func (c *ForClause) End() Pos { return redirsEnd(c.Redirs, c.Done+4) }
+
+// CaseClause is a `case` statement, e.g. `case "$ARCH" in amd64) echo x86_64 ;; *) uname -m ;; esac`.
+type CaseClause struct {
+	Position Pos
+	Word     *Word
+	Items    []*CaseItem
+	Esac     Pos
+	Redirs   []*Redirect
+}
+
+func (c *CaseClause) Pos() Pos { return c.Position }
+func (c *CaseClause) End() Pos { return redirsEnd(c.Redirs, c.Esac+4) }
+
+// CaseItem is a branch of a CaseClause, e.g. `amd64|x86_64) echo x86_64 ;;`.
+type CaseItem struct {
+	Patterns []*Word
+	Stmts    []*Stmt
+	// EndPos is the offset immediately after the item, including its terminating `;;` if any.
+	EndPos Pos
+}
+
+func (i *CaseItem) Pos() Pos { return i.Patterns[0].Pos() }
+func (i *CaseItem) End() Pos { return i.EndPos }
+
+// Assign is a variable assignment, e.g. `PATH=/usr/local/bin:$PATH`.
+type Assign struct {
+	Name *Lit
+	// Value is the assigned value, nil if it is empty, e.g. `A=`.
+	Value *Word
+}
+
+func (a *Assign) Pos() Pos { return a.Name.Pos() }
+
+func (a *Assign) End() Pos {
+	if a.Value != nil {
+		return a.Value.End()
+	}
+	return a.Name.End() + 1 // `=`
+}
+
+// RedirectOperator is the operator of a Redirect.
+type RedirectOperator string
+
+const (
+	RedirectIn       RedirectOperator = "<"
+	RedirectOut      RedirectOperator = ">"
+	RedirectAppend   RedirectOperator = ">>"
+	RedirectClobber  RedirectOperator = ">|"
+	RedirectInOut    RedirectOperator = "<>"
+	DuplicateIn      RedirectOperator = "<&"
+	DuplicateOut     RedirectOperator = ">&"
+	Heredoc          RedirectOperator = "<<"
+	HeredocStripTabs RedirectOperator = "<<-"
+)
+
+// Redirect is an I/O redirection, e.g. `2>&1` or `> /dev/null`.
+// The bodies of here-documents are not part of the shell-form command, see statement.Heredoc.
+type Redirect struct {
+	OpPos Pos
+	Op    RedirectOperator
+	// N is the file descriptor being redirected, nil if it is implied by the operator.
+	N *Lit
+	// Word is the target of the redirection, e.g. a file name, a file descriptor or a here-document delimiter.
+	Word *Word
+}
+
+func (r *Redirect) Pos() Pos {
+	if r.N != nil {
+		return r.N.Pos()
+	}
+	return r.OpPos
+}
+
+func (r *Redirect) End() Pos { return r.Word.End() }
+
+// Word is a shell word, made up of literal, quoted and expanded parts, e.g. `"$HOME"/bin`.
+type Word struct {
+	Parts []WordPart
+}
+
+func (w *Word) Pos() Pos { return w.Parts[0].Pos() }
+func (w *Word) End() Pos { return w.Parts[len(w.Parts)-1].End() }
+
+// Lit is the value of the word with its quotes and escapes removed, and whether it has one,
+// i.e. whether the word does not contain any expansions.
+func (w *Word) Lit() (string, bool) {
+	return partsLit(w.Parts)
+}
+
+func partsLit(parts []WordPart) (string, bool) {
+	var value string
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *Lit:
+			value += part.Value
+		case *SglQuoted:
+			value += part.Value
+		case *DblQuoted:
+			v, ok := partsLit(part.Parts)
+			if !ok {
+				return "", false
+			}
+			value += v
+		default:
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// WordPart is one of *Lit, *SglQuoted, *DblQuoted, *ParamExp, *CmdSubst or *ArithmExp.
+type WordPart interface {
+	Node
+	wordPartNode()
+}
+
+func (*Lit) wordPartNode()       {}
+func (*SglQuoted) wordPartNode() {}
+func (*DblQuoted) wordPartNode() {}
+func (*ParamExp) wordPartNode()  {}
+func (*CmdSubst) wordPartNode()  {}
+func (*ArithmExp) wordPartNode() {}
+
+// Lit is unquoted literal text. Value has any escape characters and escaped newlines removed.
+type Lit struct {
+	ValuePos, ValueEnd Pos
+	Value              string
+}
+
+func (l *Lit) Pos() Pos { return l.ValuePos }
+func (l *Lit) End() Pos { return l.ValueEnd }
+
+// SglQuoted is text enclosed in single quotes, e.g. `'$HOME'`. Value excludes the quotes.
+type SglQuoted struct {
+	Left, Right Pos
+	Value       string
+}
+
+func (q *SglQuoted) Pos() Pos { return q.Left }
+func (q *SglQuoted) End() Pos { return q.Right + 1 }
+
+// DblQuoted is a list of word parts enclosed in double quotes, e.g. `"$HOME/bin"`.
+type DblQuoted struct {
+	Left, Right Pos
+	Parts       []WordPart
+}
+
+func (q *DblQuoted) Pos() Pos { return q.Left }
+func (q *DblQuoted) End() Pos { return q.Right + 1 }
+
+// ParamExp is a parameter expansion, e.g. `$HOME`, `${VERSION:-latest}` or `${#PATH}`.
+type ParamExp struct {
+	Dollar, Rbrace Pos
+	// Short is whether the expansion is unbraced, e.g. `$HOME`.
+	Short bool
+	// Length is whether the expansion is of the length of the parameter, i.e. `${#name}`.
+	Length bool
+	// Param is the name of the parameter, which may also be a positional or special parameter, e.g. `1` or `?`.
+	Param *Lit
+	// Op is the operator of the expansion, one of `-`, `:-`, `=`, `:=`, `?`, `:?`, `+`, `:+`,
+	// `#`, `##`, `%`, `%%`, `/` or `//`, empty if there is none.
+	Op string
+	// Arg is the word following the operator, e.g. the default value or the pattern, nil if there is none.
+	Arg *Word
+	// Repl is the replacement of the `/` and `//` operators, nil if there is none.
+	Repl *Word
+}
+
+func (p *ParamExp) Pos() Pos { return p.Dollar }
+
+func (p *ParamExp) End() Pos {
+	if p.Short {
+		return p.Param.End()
+	}
+	return p.Rbrace + 1
+}
+
+// CmdSubst is a command substitution, e.g. `$(uname -m)` or “ `uname -m` “.
+type CmdSubst struct {
+	Left, Right Pos
+	Stmts       []*Stmt
+	// Backquotes is whether the substitution is of the legacy `` `...` `` form.
+	Backquotes bool
+}
+
+func (c *CmdSubst) Pos() Pos { return c.Left }
+func (c *CmdSubst) End() Pos { return c.Right + 1 }
+
+// ArithmExp is an arithmetic expansion, e.g. `$((1 + 2))`. The expression is not parsed.
+type ArithmExp struct {
+	Left, Right Pos
+	// Expr is the expression as written.
+	Expr string
+}
+
+func (a *ArithmExp) Pos() Pos { return a.Left }
+func (a *ArithmExp) End() Pos { return a.Right + 2 }
+
+func stmtsPos(stmts []*Stmt) Pos {
+	if len(stmts) == 0 {
+		return 0
+	}
+	return stmts[0].Pos()
+}
+
+func stmtsEnd(stmts []*Stmt) Pos {
+	if len(stmts) == 0 {
+		return 0
+	}
+	return stmts[len(stmts)-1].End()
+}
+
+func redirsEnd(redirs []*Redirect, end Pos) Pos {
+	for _, r := range redirs {
+		if r.End() > end {
+			end = r.End()
+		}
+	}
+	return end
+}
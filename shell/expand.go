@@ -0,0 +1,520 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LookupFunc looks up the value of a variable, and whether it is set.
+type LookupFunc func(name string) (value string, ok bool)
+
+/*
+Expand expands the variable references of the text the way BuildKit does for the arguments of instructions such as
+ENV, COPY or WORKDIR, removing quotes and escape characters unless RawQuotes or RawEscapes are set.
+Variables are looked up with lookup, and expand to nothing if they are not set. The supported forms are:
+
+	$name, ${name}
+	${name:-word}, ${name-word}   word if name is unset or empty, or only if unset
+	${name:+word}, ${name+word}   word if name is set and not empty, or set at all
+	${name:?word}, ${name?word}   fail with word as the message if name is unset or empty, or only if unset
+	${name#pattern}, ${name##pattern}   remove the shortest or longest prefix matching pattern
+	${name%pattern}, ${name%%pattern}   remove the shortest or longest suffix matching pattern
+	${name/pattern/word}, ${name//pattern/word}   replace the first or every match of pattern
+
+Words may themselves contain variable references. References to variables reported by SkipVar are kept as written. Patterns are shell patterns, i.e. `*`, `?` and `[...]`.
+References are not expanded within single quotes, or when `$` is preceded by the escape character.
+*/
+func (l *Lexer) Expand(text string, lookup LookupFunc) (string, error) {
+	out := &output{}
+	if err := l.expand(text, lookup, out); err != nil {
+		return "", err
+	}
+	return out.cur.String(), nil
+}
+
+// ExpandWords is Expand, additionally splitting the result into words at unquoted whitespace,
+// including any whitespace within the values of unquoted variable references, e.g. `EXPOSE $PORTS`.
+func (l *Lexer) ExpandWords(text string, lookup LookupFunc) ([]string, error) {
+	out := &output{split: true}
+	if err := l.expand(text, lookup, out); err != nil {
+		return nil, err
+	}
+	out.endWord()
+	return out.words, nil
+}
+
+func (l *Lexer) expand(text string, lookup LookupFunc, out *output) error {
+	e := &expander{Lexer: l, src: text, lookup: lookup}
+	return e.process(out, "", false)
+}
+
+// output accumulates expanded text, optionally split into words.
+type output struct {
+	split  bool
+	words  []string
+	cur    strings.Builder
+	inWord bool
+}
+
+// quoted writes text which is never split into words.
+func (o *output) quoted(s string) {
+	o.cur.WriteString(s)
+	o.inWord = true
+}
+
+// unquoted writes text which is split into words at whitespace, if splitting.
+func (o *output) unquoted(s string) {
+	if !o.split {
+		o.cur.WriteString(s)
+		return
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			o.endWord()
+			continue
+		}
+		o.cur.WriteRune(r)
+		o.inWord = true
+	}
+}
+
+func (o *output) endWord() {
+	if o.inWord {
+		o.words = append(o.words, o.cur.String())
+		o.cur.Reset()
+		o.inWord = false
+	}
+}
+
+type expander struct {
+	*Lexer
+	src    string
+	pos    int
+	lookup LookupFunc
+}
+
+func (e *expander) eof() bool {
+	return e.pos >= len(e.src)
+}
+
+func (e *expander) peek() rune {
+	r, _ := utf8.DecodeRuneInString(e.src[e.pos:])
+	return r
+}
+
+func (e *expander) next() rune {
+	r, size := utf8.DecodeRuneInString(e.src[e.pos:])
+	e.pos += size
+	return r
+}
+
+// process expands the text up to the end, or the first unquoted and unescaped character of stops.
+// If pattern is set, quoted and escaped pattern characters are escaped with `\` so that they match literally.
+func (e *expander) process(out *output, stops string, pattern bool) error {
+	for !e.eof() {
+		r := e.peek()
+		switch {
+		case strings.ContainsRune(stops, r):
+			return nil
+		case r == '\'':
+			if err := e.singleQuoted(out, pattern); err != nil {
+				return err
+			}
+		case r == '"':
+			if err := e.doubleQuoted(out, pattern); err != nil {
+				return err
+			}
+		case r == '$':
+			value, err := e.dollar()
+			if err != nil {
+				return err
+			}
+			out.unquoted(value)
+		case r == e.EscapeToken:
+			e.next()
+			if e.eof() {
+				// A trailing escape character escapes nothing, and is dropped.
+				if e.RawEscapes && !pattern {
+					out.quoted(string(e.EscapeToken))
+				}
+				return nil
+			}
+			escaped := string(e.next())
+			if e.RawEscapes && !pattern {
+				out.quoted(string(e.EscapeToken) + escaped)
+			} else {
+				out.quoted(literal(escaped, pattern))
+			}
+		default:
+			out.unquoted(string(e.next()))
+		}
+	}
+	return nil
+}
+
+func (e *expander) singleQuoted(out *output, pattern bool) error {
+	start := e.pos
+	end := strings.IndexByte(e.src[start+1:], '\'')
+	if end == -1 {
+		return fmt.Errorf("unexpected end of statement while looking for matching single-quote: %q", e.src[start:])
+	}
+	e.pos = start + 1 + end + 1
+	if e.RawQuotes && !pattern {
+		out.quoted(e.src[start:e.pos])
+	} else {
+		out.quoted(literal(e.src[start+1:e.pos-1], pattern))
+	}
+	return nil
+}
+
+func (e *expander) doubleQuoted(out *output, pattern bool) error {
+	start := e.pos
+	e.next()
+	raw := e.RawQuotes && !pattern
+	if raw {
+		out.quoted(`"`)
+	}
+	for {
+		if e.eof() {
+			return fmt.Errorf("unexpected end of statement while looking for matching double-quote: %q", e.src[start:])
+		}
+		switch r := e.peek(); r {
+		case '"':
+			e.next()
+			if raw {
+				out.quoted(`"`)
+			}
+			out.quoted("")
+			return nil
+		case '$':
+			value, err := e.dollar()
+			if err != nil {
+				return err
+			}
+			out.quoted(literal(value, pattern))
+		case e.EscapeToken:
+			e.next()
+			if e.eof() {
+				continue
+			}
+			switch escaped := e.peek(); escaped {
+			case '"', '$', e.EscapeToken:
+				e.next()
+				if e.RawEscapes && !pattern {
+					out.quoted(string(e.EscapeToken) + string(escaped))
+				} else {
+					out.quoted(literal(string(escaped), pattern))
+				}
+			default:
+				out.quoted(literal(string(e.EscapeToken), pattern))
+			}
+		default:
+			out.quoted(literal(string(e.next()), pattern))
+		}
+	}
+}
+
+// dollar expands the reference starting with `$`, which is literal if not followed by a parameter name or `{`.
+func (e *expander) dollar() (string, error) {
+	start := e.pos
+	e.next()
+	if !e.eof() && e.peek() == '{' {
+		e.next()
+		return e.braced(start)
+	}
+	name := e.name()
+	if name == "" {
+		return "$", nil
+	}
+	if e.skip(name) {
+		return e.src[start:e.pos], nil
+	}
+	value, _ := e.lookup(name)
+	return value, nil
+}
+
+func (e *expander) skip(name string) bool {
+	return e.SkipVar != nil && e.SkipVar(name)
+}
+
+// name consumes the name of a parameter: a variable name, a positional parameter, or a special parameter.
+func (e *expander) name() string {
+	start := e.pos
+	if e.eof() {
+		return ""
+	}
+	switch r := e.peek(); {
+	case '0' <= r && r <= '9':
+		for !e.eof() && '0' <= e.peek() && e.peek() <= '9' {
+			e.next()
+		}
+	case strings.ContainsRune("@*#?-$!", r):
+		e.next()
+	default:
+		for !e.eof() && e.peek() < utf8.RuneSelf && (isNameStart(byte(e.peek())) || isDigit(byte(e.peek()))) {
+			e.next()
+		}
+	}
+	return e.src[start:e.pos]
+}
+
+// braced expands a `${...}` reference, following the `${`.
+func (e *expander) braced(start int) (string, error) {
+	name := e.name()
+	if name == "" {
+		return "", fmt.Errorf("bad substitution: %q", e.reference(start))
+	}
+	skip := e.skip(name)
+	value, set := e.lookup(name)
+	if e.eof() {
+		return "", fmt.Errorf("missing `}` in substitution: %q", e.src[start:])
+	}
+
+	var result string
+	switch r := e.next(); r {
+	case '}':
+		if skip {
+			return e.src[start:e.pos], nil
+		}
+		return value, nil
+	case ':', '-', '+', '?':
+		colon := r == ':'
+		if colon {
+			if e.eof() {
+				return "", fmt.Errorf("missing `}` in substitution: %q", e.src[start:])
+			}
+			r = e.next()
+		}
+		empty := !set || (colon && value == "")
+		word, err := e.word("}", false)
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '-':
+			result = value
+			if empty {
+				result = word
+			}
+		case '+':
+			if !empty {
+				result = word
+			}
+		case '?':
+			if empty && !skip {
+				if word == "" {
+					word = "is not allowed to be unset"
+					if set {
+						word = "is not allowed to be empty"
+					}
+				}
+				return "", fmt.Errorf("%s: %s", name, word)
+			}
+			result = value
+		default:
+			return "", fmt.Errorf("unsupported modifier %q in substitution: %q", ":"+string(r), e.reference(start))
+		}
+	case '#', '%':
+		longest := !e.eof() && e.peek() == r
+		if longest {
+			e.next()
+		}
+		pattern, err := e.word("}", true)
+		if err != nil {
+			return "", err
+		}
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern in substitution %q: %w", e.reference(start), err)
+		}
+		if r == '#' {
+			result = trimPrefix(value, re, longest)
+		} else {
+			result = trimSuffix(value, re, longest)
+		}
+	case '/':
+		all := !e.eof() && e.peek() == '/'
+		if all {
+			e.next()
+		}
+		pattern, err := e.word("/}", true)
+		if err != nil {
+			return "", err
+		}
+		var replacement string
+		if !e.eof() && e.peek() == '/' {
+			e.next()
+			if replacement, err = e.word("}", false); err != nil {
+				return "", err
+			}
+		}
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern in substitution %q: %w", e.reference(start), err)
+		}
+		result = replace(value, re, replacement, all)
+	default:
+		return "", fmt.Errorf("unsupported modifier %q in substitution: %q", string(r), e.reference(start))
+	}
+
+	if e.eof() || e.peek() != '}' {
+		return "", fmt.Errorf("missing `}` in substitution: %q", e.src[start:])
+	}
+	e.next()
+	if skip {
+		return e.src[start:e.pos], nil
+	}
+	return result, nil
+}
+
+// word expands the word following the modifier of a `${...}` reference, up to the first of stops.
+func (e *expander) word(stops string, pattern bool) (string, error) {
+	out := &output{}
+	if err := e.process(out, stops, pattern); err != nil {
+		return "", err
+	}
+	return out.cur.String(), nil
+}
+
+// reference is the text of the reference starting at start, for error messages.
+func (e *expander) reference(start int) string {
+	if end := strings.IndexByte(e.src[start:], '}'); end != -1 {
+		return e.src[start : start+end+1]
+	}
+	return e.src[start:]
+}
+
+// literal escapes the characters of s which are special in patterns, if expanding a pattern.
+func literal(s string, pattern bool) string {
+	if !pattern {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// compilePattern compiles a shell pattern into a regular expression matching the whole of a string.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	re.WriteString("^(?s:")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := runes[i+1 : end]
+			re.WriteString("[")
+			if class[0] == '!' || class[0] == '^' {
+				re.WriteString("^")
+				class = class[1:]
+			}
+			for _, c := range class {
+				if c == '\\' || c == '[' || c == ']' {
+					re.WriteRune('\\')
+				}
+				re.WriteRune(c)
+			}
+			re.WriteString("]")
+			i = end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString(")$")
+	return regexp.Compile(re.String())
+}
+
+// boundaries are the byte offsets of the starts of the runes of s, and its end.
+func boundaries(s string) []int {
+	offsets := make([]int, 0, len(s)+1)
+	for i := range s {
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(s))
+}
+
+func trimPrefix(s string, re *regexp.Regexp, longest bool) string {
+	offsets := boundaries(s)
+	for j := range offsets {
+		i := offsets[j]
+		if longest {
+			i = offsets[len(offsets)-1-j]
+		}
+		if re.MatchString(s[:i]) {
+			return s[i:]
+		}
+	}
+	return s
+}
+
+func trimSuffix(s string, re *regexp.Regexp, longest bool) string {
+	offsets := boundaries(s)
+	for j := range offsets {
+		i := offsets[len(offsets)-1-j]
+		if longest {
+			i = offsets[j]
+		}
+		if re.MatchString(s[i:]) {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// replace replaces the first, or every, longest non-empty match of the pattern within s.
+func replace(s string, re *regexp.Regexp, replacement string, all bool) string {
+	offsets := boundaries(s)
+	var b strings.Builder
+	last := 0
+	for j := 0; j < len(offsets)-1; j++ {
+		start := offsets[j]
+		if start < last {
+			continue
+		}
+		for k := len(offsets) - 1; k > j; k-- {
+			end := offsets[k]
+			if re.MatchString(s[start:end]) {
+				b.WriteString(s[last:start])
+				b.WriteString(replacement)
+				last = end
+				break
+			}
+		}
+		if last > start && !all {
+			break
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
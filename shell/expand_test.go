@@ -0,0 +1,265 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testEnv = map[string]string{
+	"PWD":   "/home",
+	"SHELL": "bash",
+	"NULL":  "",
+	"FILE":  "archive.tar.gz",
+	"PORTS": "80/tcp  443",
+	"GLOB":  "*",
+}
+
+func testLookup(name string) (string, bool) {
+	value, ok := testEnv[name]
+	return value, ok
+}
+
+// The cases mirror those of BuildKit's shell lexer, see: https://github.com/moby/buildkit/blob/master/frontend/dockerfile/shell/envVarTest
+func TestExpand(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{`hello`, `hello`},
+		{`he'll'o`, `hello`},
+		{`he\'llo`, `he'llo`},
+		{`abc\tdef`, `abctdef`},
+		{`"abc\tdef"`, `abc\tdef`},
+		{`"abc\\tdef"`, `abc\tdef`},
+		{`'abc\tdef'`, `abc\tdef`},
+		{`hello\`, `hello`},
+		{`hello\\`, `hello\`},
+		{`"hel'lo"`, `hel'lo`},
+		{`'hello\'`, `hello\`},
+		{`'hello\there'`, `hello\there`},
+		{`"''"`, `''`},
+		{`$.`, `$.`},
+		{`$`, `$`},
+		{`he$1x`, `hex`},
+		{`he$.x`, `he$.x`},
+		{`he$pwd.`, `he.`},
+		{`he$PWD`, `he/home`},
+		{`he\$PWD`, `he$PWD`},
+		{`he\\$PWD`, `he\/home`},
+		{`"he\$PWD"`, `he$PWD`},
+		{`"he\\$PWD"`, `he\/home`},
+		{`\${}`, `${}`},
+		{`he\${}xx`, `he${}xx`},
+		{`he${hi}xx`, `hexx`},
+		{`he${PWD}`, `he/home`},
+		{`he${XXX:-000}xx`, `he000xx`},
+		{`he${PWD:-000}xx`, `he/homexx`},
+		{`he${XXX:-$PWD}xx`, `he/homexx`},
+		{`he${XXX:-${PWD:-yyy}}xx`, `he/homexx`},
+		{`he${XXX:-${YYY:-yyy}}xx`, `heyyyxx`},
+		{`he${PWD?}`, `he/home`},
+		{`he${PWD:?}`, `he/home`},
+		{`he${NULL?}`, `he`},
+		{`he${XXX:+${PWD}}xx`, `hexx`},
+		{`he${PWD:+${XXX}}xx`, `hexx`},
+		{`he${PWD:+${SHELL}}xx`, `hebashxx`},
+		{`he${XXX:+000}xx`, `hexx`},
+		{`he${PWD:+000}xx`, `he000xx`},
+		{`'he${XX}'`, `he${XX}`},
+		{`"he${PWD}"`, `he/home`},
+		{`"he'$PWD'"`, `he'/home'`},
+		{`"$PWD"`, `/home`},
+		{`'$PWD'`, `$PWD`},
+		{`'\$PWD'`, `\$PWD`},
+		{`'"hello"'`, `"hello"`},
+		{`he${PWD+}xx`, `hexx`},
+		{`he${PWD-}xx`, `he/homexx`},
+		{`he${XXX+000}xx`, `hexx`},
+		{`he${XXX-000}xx`, `he000xx`},
+		{`he${NULL+000}xx`, `he000xx`},
+		{`he${NULL-000}xx`, `hexx`},
+		{`he${NULL:-000}xx`, `he000xx`},
+		{`he${NULL:+000}xx`, `hexx`},
+		{`${XXX:-"a  b"}`, `a  b`},
+		{`${XXX:-'${PWD}'}`, `${PWD}`},
+		{`${FILE#*.}`, `tar.gz`},
+		{`${FILE##*.}`, `gz`},
+		{`${FILE%.*}`, `archive.tar`},
+		{`${FILE%%.*}`, `archive`},
+		{`${FILE#archive}`, `.tar.gz`},
+		{`${FILE#x*}`, `archive.tar.gz`},
+		{`${FILE%.[gt]z}`, `archive.tar`},
+		{`${FILE%.[!g]z}`, `archive.tar.gz`},
+		{`${FILE%?z}`, `archive.tar.`},
+		{`${FILE#[}`, `archive.tar.gz`},
+		{`${XXX#*}`, ``},
+		{`${PWD#"/"}`, `home`},
+		{`${GLOB#"*"}`, ``},
+		{`${FILE#"*"}`, `archive.tar.gz`},
+		{`${FILE#\*}`, `archive.tar.gz`},
+		{`${FILE/a/A}`, `Archive.tar.gz`},
+		{`${FILE//a/A}`, `Archive.tAr.gz`},
+		{`${FILE/.*/}`, `archive`},
+		{`${FILE/.t*./-}`, `archive-gz`},
+		{`${FILE/x/y}`, `archive.tar.gz`},
+		{`${FILE/a}`, `rchive.tar.gz`},
+		{`${FILE//[.]/_}`, `archive_tar_gz`},
+		{`${FILE/a/$SHELL}`, `bashrchive.tar.gz`},
+		{`${PWD/\//:}`, `:home`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			got, err := NewLexer(DefaultEscapeToken).Expand(tc.text, testLookup)
+			if err != nil {
+				t.Fatalf("Expand(%q) returned an unexpected error: %v", tc.text, err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expand(%q) = %q, expected %q", tc.text, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{`he'llo`, `unexpected end of statement while looking for matching single-quote: "'llo"`},
+		{`he\\'llo`, `unexpected end of statement while looking for matching single-quote: "'llo"`},
+		{`"hello`, `unexpected end of statement while looking for matching double-quote: "\"hello"`},
+		{`"hello\"`, `unexpected end of statement while looking for matching double-quote: "\"hello\\\""`},
+		{`'hello`, `unexpected end of statement while looking for matching single-quote: "'hello"`},
+		{`${}`, `bad substitution: "${}"`},
+		{`he${}xx`, `bad substitution: "${}"`},
+		{`he${.}`, `bad substitution: "${.}"`},
+		{`he${PWD`, "missing `}` in substitution: \"${PWD\""},
+		{`he${XXX:-000`, "missing `}` in substitution: \"${XXX:-000\""},
+		{`he${XXX:YYY}`, `unsupported modifier ":Y" in substitution: "${XXX:YYY}"`},
+		{`he${PWD=000}xx`, `unsupported modifier "=" in substitution: "${PWD=000}"`},
+		{`he${XXX:=000}xx`, `unsupported modifier ":=" in substitution: "${XXX:=000}"`},
+		{`he${XXX?}`, `XXX: is not allowed to be unset`},
+		{`he${XXX:?}`, `XXX: is not allowed to be unset`},
+		{`he${NULL:?}`, `NULL: is not allowed to be empty`},
+		{`he${XXX:?must be set}`, `XXX: must be set`},
+		{`he${XXX:-${YYY:?$PWD}}`, `YYY: /home`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			got, err := NewLexer(DefaultEscapeToken).Expand(tc.text, testLookup)
+			if err == nil {
+				t.Fatalf("Expand(%q) = %q, expected an error", tc.text, got)
+			}
+			if err.Error() != tc.expected {
+				t.Errorf("Expand(%q) returned error %q, expected %q", tc.text, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestExpandRaw(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		escapeToken rune
+		text        string
+		expected    string
+	}{
+		{
+			desc:        "quotes are kept",
+			escapeToken: '\\',
+			text:        `"$PWD" '$PWD' $PWD`,
+			expected:    `"/home" '$PWD' /home`,
+		},
+		{
+			desc:        "escapes are kept",
+			escapeToken: '\\',
+			text:        `\$PWD "\$PWD" \"$SHELL\" \`,
+			expected:    `\$PWD "\$PWD" \"bash\" \`,
+		},
+		{
+			desc:        "quoted words are kept",
+			escapeToken: '\\',
+			text:        `${XXX:-"a b"}`,
+			expected:    `"a b"`,
+		},
+		{
+			desc:        "patterns are unquoted",
+			escapeToken: '\\',
+			text:        `${GLOB#"*"}x`,
+			expected:    `x`,
+		},
+		{
+			desc:        "backtick escape",
+			escapeToken: '`',
+			text:        "C:\\$SHELL `$SHELL ``$SHELL",
+			expected:    "C:\\bash `$SHELL ``bash",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			l := &Lexer{EscapeToken: tc.escapeToken, RawQuotes: true, RawEscapes: true}
+			got, err := l.Expand(tc.text, testLookup)
+			if err != nil {
+				t.Fatalf("Expand(%q) returned an unexpected error: %v", tc.text, err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expand(%q) = %q, expected %q", tc.text, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestExpandWords(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected []string
+	}{
+		{``, nil},
+		{`  a  b `, []string{"a", "b"}},
+		{`$PORTS 8080`, []string{"80/tcp", "443", "8080"}},
+		{`"$PORTS" 8080`, []string{"80/tcp  443", "8080"}},
+		{`a"$NULL"b '' $NULL`, []string{"ab", ""}},
+		{`${XXX:-1 2}`, []string{"1", "2"}},
+		{`a\ b`, []string{"a b"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			got, err := NewLexer(DefaultEscapeToken).ExpandWords(tc.text, testLookup)
+			if err != nil {
+				t.Fatalf("ExpandWords(%q) returned an unexpected error: %v", tc.text, err)
+			}
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("ExpandWords(%q) mismatch (-want +got):\n%s", tc.text, diff)
+			}
+		})
+	}
+}
+
+func TestExpandSkipVar(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{`$SKIP:$PWD`, `$SKIP:/home`},
+		{`${SKIP}${PWD}`, `${SKIP}/home`},
+		{`"${SKIP:-$PWD}"`, `${SKIP:-$PWD}`},
+		{`${SKIP:?must be set}`, `${SKIP:?must be set}`},
+		{`${SKIP#*.}.${FILE#*.}`, `${SKIP#*.}.tar.gz`},
+		{`${XXX:-$SKIP}`, `$SKIP`},
+		{`'$SKIP' \$SKIP`, `$SKIP $SKIP`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			l := NewLexer(DefaultEscapeToken)
+			l.SkipVar = func(name string) bool { return name == "SKIP" }
+			got, err := l.Expand(tc.text, testLookup)
+			if err != nil {
+				t.Fatalf("Expand(%q) returned an unexpected error: %v", tc.text, err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expand(%q) = %q, expected %q", tc.text, got, tc.expected)
+			}
+		})
+	}
+}
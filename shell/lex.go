@@ -0,0 +1,161 @@
+// Package shell interprets the shell-form arguments of Dockerfile instructions the way BuildKit does.
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DefaultEscapeToken is the escape character of Dockerfiles which do not set the `escape` parser directive.
+const DefaultEscapeToken = '\\'
+
+// Token is a single word of shell-form arguments, e.g. `"my file"`.
+type Token struct {
+	// Raw is the word as written, including any quotes and escape characters.
+	Raw string
+	// Value is the word with its quotes and escape characters removed.
+	Value string
+	// Quoted is whether any part of the word was enclosed in quotes.
+	Quoted bool
+	// Offset is the byte offset of the start of the word within the lexed text.
+	Offset int
+}
+
+// Lexer splits shell-form arguments into words, and expands their variable references.
+type Lexer struct {
+	// EscapeToken is the escape character, `\` unless set to `` ` `` by the `escape` parser directive.
+	EscapeToken rune
+	// RawQuotes keeps quotes in the text produced by Expand, rather than removing them.
+	RawQuotes bool
+	// RawEscapes keeps escape characters in the text produced by Expand, rather than removing them.
+	RawEscapes bool
+	// SkipVar, if set, reports variables whose references are kept as written by Expand rather than expanded,
+	// e.g. because their values are not known yet.
+	SkipVar func(name string) bool
+}
+
+// NewLexer returns a Lexer which honours the given escape character.
+func NewLexer(escapeToken rune) *Lexer {
+	return &Lexer{EscapeToken: escapeToken}
+}
+
+// Lex splits the text into words using the default escape character, see Lexer.Lex.
+func Lex(text string) ([]Token, error) {
+	return NewLexer(DefaultEscapeToken).Lex(text)
+}
+
+/*
+Lex splits the text into words, which are separated by unquoted whitespace:
+
+  - Within single quotes, every character is preserved literally.
+  - Within double quotes, the escape character only escapes `"`, `$` and itself, otherwise it is preserved.
+  - Elsewhere, the escape character preserves the following character literally.
+
+Quotes and escape characters are removed from the Value of each word, but variable references are not expanded.
+Lex fails if a quote is not terminated.
+*/
+func (l *Lexer) Lex(text string) ([]Token, error) {
+	var tokens []Token
+	offset := 0
+	for {
+		tok, rest, ok, err := l.Next(text[offset:])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return tokens, nil
+		}
+		tok.Offset += offset
+		tokens = append(tokens, tok)
+		offset = len(text) - len(rest)
+	}
+}
+
+// Next lexes the first word of the text, see Lex, returning it along with the text following it.
+// ok is false if the text is only whitespace.
+func (l *Lexer) Next(text string) (tok Token, rest string, ok bool, err error) {
+	start := strings.IndexFunc(text, func(c rune) bool { return !unicode.IsSpace(c) })
+	if start == -1 {
+		return Token{}, "", false, nil
+	}
+	tok.Offset = start
+	end := len(text)
+	var value strings.Builder
+	var quote rune
+	escaped := false
+	for i, c := range text[start:] {
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && c != '"' && c != '$' && c != l.EscapeToken {
+				value.WriteRune(l.EscapeToken)
+			}
+			value.WriteRune(c)
+			continue
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				value.WriteRune(c)
+			}
+			continue
+		case c == l.EscapeToken:
+			escaped = true
+			continue
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				value.WriteRune(c)
+			}
+			continue
+		case c == '\'' || c == '"':
+			quote = c
+			tok.Quoted = true
+			continue
+		case !unicode.IsSpace(c):
+			value.WriteRune(c)
+			continue
+		}
+		end = start + i
+		break
+	}
+	switch quote {
+	case '"':
+		return Token{}, text, false, fmt.Errorf("unexpected end of statement while looking for matching double-quote: %q", text[start:])
+	case '\'':
+		return Token{}, text, false, fmt.Errorf("unexpected end of statement while looking for matching single-quote: %q", text[start:])
+	}
+	// A trailing escape character escapes nothing, and is dropped.
+	tok.Raw, tok.Value = text[start:end], value.String()
+	return tok, text[end:], true, nil
+}
+
+// Fields splits the text into the raw words of Lex, falling back to splitting on whitespace if a quote is not terminated.
+// This suits shell-form commands, whose quoting is only checked by the shell when they are run.
+func (l *Lexer) Fields(text string) []string {
+	tokens, err := l.Lex(text)
+	if err != nil {
+		return strings.Fields(text)
+	}
+	return Raw(tokens)
+}
+
+// Raw are the raw words of the tokens.
+func Raw(tokens []Token) []string {
+	words := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		words = append(words, tok.Raw)
+	}
+	return words
+}
+
+// Values are the values of the tokens.
+func Values(tokens []Token) []string {
+	values := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		values = append(values, tok.Value)
+	}
+	return values
+}
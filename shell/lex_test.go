@@ -0,0 +1,114 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLex(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		escapeToken rune
+		text        string
+		expected    []Token
+	}{
+		{
+			desc:        "empty",
+			escapeToken: '\\',
+			text:        "  \t ",
+		},
+		{
+			desc:        "unquoted",
+			escapeToken: '\\',
+			text:        " echo  hello\tworld ",
+			expected: []Token{
+				{Raw: "echo", Value: "echo", Offset: 1},
+				{Raw: "hello", Value: "hello", Offset: 7},
+				{Raw: "world", Value: "world", Offset: 13},
+			},
+		},
+		{
+			desc:        "single quotes",
+			escapeToken: '\\',
+			text:        `'a  b' 'c\'d`,
+			expected: []Token{
+				{Raw: `'a  b'`, Value: "a  b", Quoted: true},
+				{Raw: `'c\'d`, Value: `c\d`, Quoted: true, Offset: 7},
+			},
+		},
+		{
+			desc:        "double quotes",
+			escapeToken: '\\',
+			text:        `"a  b" "\"\$\\\n" "$HOME"`,
+			expected: []Token{
+				{Raw: `"a  b"`, Value: "a  b", Quoted: true},
+				{Raw: `"\"\$\\\n"`, Value: `"$\\n`, Quoted: true, Offset: 7},
+				{Raw: `"$HOME"`, Value: "$HOME", Quoted: true, Offset: 18},
+			},
+		},
+		{
+			desc:        "partially quoted",
+			escapeToken: '\\',
+			text:        `--opt="a b"c`,
+			expected: []Token{
+				{Raw: `--opt="a b"c`, Value: "--opt=a bc", Quoted: true},
+			},
+		},
+		{
+			desc:        "escaped",
+			escapeToken: '\\',
+			text:        `my\ file \'a \`,
+			expected: []Token{
+				{Raw: `my\ file`, Value: "my file"},
+				{Raw: `\'a`, Value: "'a", Offset: 9},
+				{Raw: `\`, Value: "", Offset: 13},
+			},
+		},
+		{
+			desc:        "backtick escape",
+			escapeToken: '`',
+			text:        "C:\\my` files\\ \"C:\\a `\"b`\"\"",
+			expected: []Token{
+				{Raw: "C:\\my` files\\", Value: `C:\my files\`},
+				{Raw: "\"C:\\a `\"b`\"\"", Value: `C:\a "b"`, Quoted: true, Offset: 14},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			tokens, err := NewLexer(tc.escapeToken).Lex(tc.text)
+			if err != nil {
+				t.Fatalf("Lex() error'd: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, tokens); diff != "" {
+				t.Error("mismatch (-want +got):\n", diff)
+			}
+		})
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	for _, text := range []string{`echo "a b`, `echo 'a b`, `echo "a\"`} {
+		if tokens, err := Lex(text); err == nil {
+			t.Errorf("Lex(%q) = %v, want an error", text, tokens)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected []string
+	}{
+		{text: `echo "a  b" c`, expected: []string{"echo", `"a  b"`, "c"}},
+		{text: `echo "a  b c`, expected: []string{"echo", `"a`, "b", "c"}},
+	}
+	for _, tc := range testCases {
+		if diff := cmp.Diff(tc.expected, NewLexer(DefaultEscapeToken).Fields(tc.text)); diff != "" {
+			t.Errorf("Fields(%q) mismatch (-want +got):\n%s", tc.text, diff)
+		}
+	}
+}
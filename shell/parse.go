@@ -0,0 +1,809 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is a syntax error in a shell script.
+type ParseError struct {
+	// Pos is the offset within the script at which the error was detected.
+	Pos Pos
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("shell syntax error at offset %d: %s", e.Pos, e.Msg)
+}
+
+/*
+Parse parses a POSIX shell script, e.g. the shell-form command of a RUN instruction, into a syntax tree:
+
+	apt-get update && apt-get install -y curl | tee log
+
+is a *BinaryCmd of the `apt-get update` *CallExpr and a *Pipeline of `apt-get install -y curl` and `tee log`.
+Positions within the tree are byte offsets within src. Variables are not expanded, and the bodies of
+here-documents are not part of the script. Function definitions are not supported.
+*/
+func Parse(src string) (script *Script, err error) {
+	p := &parser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			script, err = nil, b.err
+		}
+	}()
+	stmts := p.stmtList()
+	if !p.eof() {
+		p.errorf(p.pos, "unexpected %s", p.found())
+	}
+	return &Script{Stmts: stmts}, nil
+}
+
+// bailout unwinds the parser on the first syntax error.
+type bailout struct {
+	err *ParseError
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) {
+	panic(bailout{&ParseError{Pos: Pos(pos), Msg: fmt.Sprintf(format, args...)}})
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+// found describes the next reserved word or character, for error messages.
+func (p *parser) found() string {
+	if p.eof() {
+		return "end of script"
+	}
+	for _, kw := range reservedWords {
+		if p.atKeyword(kw) {
+			return "`" + kw + "`"
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	if r == '\n' {
+		return "newline"
+	}
+	return fmt.Sprintf("`%c`", r)
+}
+
+// isMeta is whether the character ends an unquoted word.
+func isMeta(c byte) bool {
+	return strings.IndexByte(" \t\r\n;&|()<>", c) != -1
+}
+
+// atKeyword is whether the next word is the given reserved word.
+func (p *parser) atKeyword(kw string) bool {
+	if !p.hasPrefix(kw) {
+		return false
+	}
+	end := p.pos + len(kw)
+	return end == len(p.src) || isMeta(p.src[end])
+}
+
+// expectKeyword consumes the given reserved word, returning its position.
+func (p *parser) expectKeyword(kw string) Pos {
+	if !p.atKeyword(kw) {
+		p.errorf(p.pos, "expected `%s`, found %s", kw, p.found())
+	}
+	pos := p.pos
+	p.pos += len(kw)
+	return Pos(pos)
+}
+
+// skipBlanks skips spaces, tabs, escaped newlines and comments, but not newlines.
+func (p *parser) skipBlanks() {
+	for !p.eof() {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n':
+			p.pos += 2
+		case c == '#':
+			for !p.eof() && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// skipLinebreaks skips blanks and newlines.
+func (p *parser) skipLinebreaks() {
+	for {
+		p.skipBlanks()
+		if p.peek() != '\n' {
+			return
+		}
+		p.pos++
+	}
+}
+
+// stmtList parses a list of statements separated by `;`, `&` or newlines, up to the end of the script
+// or the first of the given reserved words, `)` or `;;` in command position.
+func (p *parser) stmtList(stops ...string) []*Stmt {
+	var stmts []*Stmt
+	for {
+		p.skipLinebreaks()
+		if p.eof() || p.atStop(stops) {
+			return stmts
+		}
+		stmt := &Stmt{Cmd: p.andOr()}
+		stmts = append(stmts, stmt)
+		p.skipBlanks()
+		switch {
+		case p.hasPrefix(";;"):
+			return stmts
+		case p.peek() == ';' || p.peek() == '\n':
+			p.pos++
+		case p.peek() == '&':
+			p.pos++
+			stmt.Background = true
+		default:
+			return stmts
+		}
+	}
+}
+
+// compoundList is a stmtList of at least one statement.
+func (p *parser) compoundList(stops ...string) []*Stmt {
+	stmts := p.stmtList(stops...)
+	if len(stmts) == 0 {
+		p.errorf(p.pos, "expected a command, found %s", p.found())
+	}
+	return stmts
+}
+
+func (p *parser) atStop(stops []string) bool {
+	for _, stop := range stops {
+		if stop == ")" || stop == ";;" {
+			if p.hasPrefix(stop) {
+				return true
+			}
+		} else if p.atKeyword(stop) {
+			return true
+		}
+	}
+	return false
+}
+
+// andOr parses pipelines separated by `&&` or `||`.
+func (p *parser) andOr() Command {
+	x := p.pipeline()
+	for {
+		p.skipBlanks()
+		var op BinaryOperator
+		switch {
+		case p.hasPrefix(string(AndOperator)):
+			op = AndOperator
+		case p.hasPrefix(string(OrOperator)):
+			op = OrOperator
+		default:
+			return x
+		}
+		opPos := p.pos
+		p.pos += len(op)
+		p.skipLinebreaks()
+		x = &BinaryCmd{OpPos: Pos(opPos), Op: op, X: x, Y: p.pipeline()}
+	}
+}
+
+// pipeline parses commands separated by `|`, optionally negated with `!`.
+func (p *parser) pipeline() Command {
+	p.skipBlanks()
+	bang := Pos(-1)
+	if p.atKeyword("!") {
+		bang = Pos(p.pos)
+		p.pos++
+		p.skipBlanks()
+	}
+	cmds := []Command{p.command()}
+	for {
+		p.skipBlanks()
+		if p.peek() != '|' || p.hasPrefix(string(OrOperator)) {
+			break
+		}
+		p.pos++
+		p.skipLinebreaks()
+		cmds = append(cmds, p.command())
+	}
+	if bang < 0 && len(cmds) == 1 {
+		return cmds[0]
+	}
+	return &Pipeline{Bang: bang, Cmds: cmds}
+}
+
+// reservedWords are the reserved words which may only appear in command position within a compound command.
+var reservedWords = []string{"then", "elif", "else", "fi", "do", "done", "esac", "}"}
+
+func (p *parser) command() Command {
+	p.skipBlanks()
+	pos := p.pos
+	switch {
+	case p.peek() == '(':
+		p.pos++
+		stmts := p.compoundList(")")
+		if p.peek() != ')' {
+			p.errorf(p.pos, "expected `)` to close the subshell at offset %d, found %s", pos, p.found())
+		}
+		rparen := p.pos
+		p.pos++
+		return &Subshell{Lparen: Pos(pos), Rparen: Pos(rparen), Stmts: stmts, Redirs: p.redirects()}
+	case p.atKeyword("{"):
+		p.pos++
+		stmts := p.compoundList("}")
+		rbrace := p.expectKeyword("}")
+		return &Block{Lbrace: Pos(pos), Rbrace: rbrace, Stmts: stmts, Redirs: p.redirects()}
+	case p.atKeyword("if"):
+		c := p.ifClause(len("if"))
+		c.Redirs = p.redirects()
+		return c
+	case p.atKeyword("while") || p.atKeyword("until"):
+		return p.whileClause()
+	case p.atKeyword("for"):
+		return p.forClause()
+	case p.atKeyword("case"):
+		return p.caseClause()
+	}
+	for _, kw := range reservedWords {
+		if p.atKeyword(kw) {
+			p.errorf(pos, "unexpected `%s`", kw)
+		}
+	}
+	return p.simpleCommand()
+}
+
+// ifClause parses an `if` or `elif` branch, starting with the keyword of the given length, up to and including the `fi`.
+func (p *parser) ifClause(kwLen int) *IfClause {
+	c := &IfClause{Position: Pos(p.pos)}
+	p.pos += kwLen
+	c.Cond = p.compoundList("then")
+	p.expectKeyword("then")
+	c.Then = p.compoundList("elif", "else", "fi")
+	switch {
+	case p.atKeyword("elif"):
+		elif := p.ifClause(len("elif"))
+		c.Else = []*Stmt{{Cmd: elif}}
+		c.Fi = elif.Fi
+		return c
+	case p.atKeyword("else"):
+		p.pos += len("else")
+		c.Else = p.compoundList("fi")
+	}
+	c.Fi = p.expectKeyword("fi")
+	return c
+}
+
+func (p *parser) whileClause() *WhileClause {
+	c := &WhileClause{Position: Pos(p.pos), Until: p.atKeyword("until")}
+	if c.Until {
+		p.pos += len("until")
+	} else {
+		p.pos += len("while")
+	}
+	c.Cond = p.compoundList("do")
+	c.Do, c.Done = p.doGroup()
+	c.Redirs = p.redirects()
+	return c
+}
+
+func (p *parser) forClause() *ForClause {
+	c := &ForClause{Position: Pos(p.pos)}
+	p.pos += len("for")
+	p.skipBlanks()
+	n := nameLen(p.src[p.pos:])
+	if n == 0 || (p.pos+n < len(p.src) && !isMeta(p.src[p.pos+n])) {
+		p.errorf(p.pos, "expected a variable name after `for`, found %s", p.found())
+	}
+	c.Name = &Lit{ValuePos: Pos(p.pos), ValueEnd: Pos(p.pos + n), Value: p.src[p.pos : p.pos+n]}
+	p.pos += n
+
+	p.skipLinebreaks()
+	if p.atKeyword("in") {
+		p.pos += len("in")
+		c.Items = []*Word{}
+		for {
+			p.skipBlanks()
+			if p.peek() == ';' || p.peek() == '\n' {
+				p.pos++
+				break
+			}
+			if p.eof() || isMeta(p.peek()) {
+				p.errorf(p.pos, "expected `;` or a newline before `do`, found %s", p.found())
+			}
+			c.Items = append(c.Items, p.word())
+		}
+	} else if p.peek() == ';' {
+		p.pos++
+	}
+	p.skipLinebreaks()
+	c.Do, c.Done = p.doGroup()
+	c.Redirs = p.redirects()
+	return c
+}
+
+// doGroup parses `do <list> done`, returning the list and the position of `done`.
+func (p *parser) doGroup() ([]*Stmt, Pos) {
+	p.expectKeyword("do")
+	stmts := p.compoundList("done")
+	return stmts, p.expectKeyword("done")
+}
+
+func (p *parser) caseClause() *CaseClause {
+	c := &CaseClause{Position: Pos(p.pos)}
+	p.pos += len("case")
+	p.skipBlanks()
+	c.Word = p.word()
+	p.skipLinebreaks()
+	p.expectKeyword("in")
+	for {
+		p.skipLinebreaks()
+		if p.atKeyword("esac") {
+			break
+		}
+		if p.eof() {
+			p.errorf(p.pos, "expected `esac`, found %s", p.found())
+		}
+		if p.peek() == '(' {
+			p.pos++
+		}
+		item := &CaseItem{}
+		for {
+			p.skipBlanks()
+			item.Patterns = append(item.Patterns, p.word())
+			p.skipBlanks()
+			if p.peek() != '|' {
+				break
+			}
+			p.pos++
+		}
+		if p.peek() != ')' {
+			p.errorf(p.pos, "expected `)` after the case pattern, found %s", p.found())
+		}
+		p.pos++
+		item.Stmts = p.stmtList(";;", "esac")
+		if p.hasPrefix(";;") {
+			p.pos += len(";;")
+			item.EndPos = Pos(p.pos)
+		} else if len(item.Stmts) > 0 {
+			item.EndPos = stmtsEnd(item.Stmts)
+		} else {
+			item.EndPos = item.Patterns[len(item.Patterns)-1].End() + 1
+		}
+		c.Items = append(c.Items, item)
+	}
+	c.Esac = p.expectKeyword("esac")
+	c.Redirs = p.redirects()
+	return c
+}
+
+func (p *parser) simpleCommand() *CallExpr {
+	c := &CallExpr{}
+	for {
+		p.skipBlanks()
+		if r := p.redirect(); r != nil {
+			c.Redirs = append(c.Redirs, r)
+			continue
+		}
+		if p.eof() || isMeta(p.peek()) {
+			break
+		}
+		if len(c.Args) == 0 {
+			if a := p.assign(); a != nil {
+				c.Assigns = append(c.Assigns, a)
+				continue
+			}
+		}
+		c.Args = append(c.Args, p.word())
+	}
+	if p.peek() == '(' {
+		if len(c.Args) == 1 && len(c.Assigns) == 0 && len(c.Redirs) == 0 {
+			p.errorf(p.pos, "function definitions are not supported")
+		}
+		p.errorf(p.pos, "unexpected `(`")
+	}
+	if len(c.Assigns)+len(c.Args)+len(c.Redirs) == 0 {
+		p.errorf(p.pos, "expected a command, found %s", p.found())
+	}
+	return c
+}
+
+// redirects parses the redirections following a compound command.
+func (p *parser) redirects() []*Redirect {
+	var redirs []*Redirect
+	for {
+		p.skipBlanks()
+		r := p.redirect()
+		if r == nil {
+			return redirs
+		}
+		redirs = append(redirs, r)
+	}
+}
+
+// redirectOperators are the redirection operators, longest first.
+var redirectOperators = []RedirectOperator{
+	HeredocStripTabs, Heredoc, DuplicateIn, RedirectInOut, RedirectIn,
+	RedirectAppend, RedirectClobber, DuplicateOut, RedirectOut,
+}
+
+// redirect parses a redirection, if there is one.
+func (p *parser) redirect() *Redirect {
+	start, i := p.pos, p.pos
+	for i < len(p.src) && isDigit(p.src[i]) {
+		i++
+	}
+	var op RedirectOperator
+	for _, o := range redirectOperators {
+		if strings.HasPrefix(p.src[i:], string(o)) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return nil
+	}
+	r := &Redirect{OpPos: Pos(i), Op: op}
+	if i > start {
+		r.N = &Lit{ValuePos: Pos(start), ValueEnd: Pos(i), Value: p.src[start:i]}
+	}
+	p.pos = i + len(op)
+	p.skipBlanks()
+	if p.eof() || isMeta(p.peek()) {
+		p.errorf(p.pos, "expected a word after `%s`, found %s", op, p.found())
+	}
+	r.Word = p.word()
+	return r
+}
+
+// assign parses a variable assignment, if there is one.
+func (p *parser) assign() *Assign {
+	n := nameLen(p.src[p.pos:])
+	if n == 0 || p.pos+n >= len(p.src) || p.src[p.pos+n] != '=' {
+		return nil
+	}
+	a := &Assign{Name: &Lit{ValuePos: Pos(p.pos), ValueEnd: Pos(p.pos + n), Value: p.src[p.pos : p.pos+n]}}
+	p.pos += n + 1
+	if !p.eof() && !isMeta(p.peek()) {
+		a.Value = p.word()
+	}
+	return a
+}
+
+func (p *parser) word() *Word {
+	parts := p.wordParts(isMeta)
+	if len(parts) == 0 {
+		p.errorf(p.pos, "expected a word, found %s", p.found())
+	}
+	return &Word{Parts: parts}
+}
+
+// litBuilder accumulates the unquoted literal text of a word.
+type litBuilder struct {
+	parts *[]WordPart
+	start int
+	value strings.Builder
+}
+
+func newLitBuilder(parts *[]WordPart) *litBuilder {
+	return &litBuilder{parts: parts, start: -1}
+}
+
+func (b *litBuilder) add(pos int, s string) {
+	if b.start == -1 {
+		b.start = pos
+	}
+	b.value.WriteString(s)
+}
+
+// flush appends the accumulated text, which ends at the given position, as a *Lit.
+func (b *litBuilder) flush(end int) {
+	if b.start == -1 {
+		return
+	}
+	*b.parts = append(*b.parts, &Lit{ValuePos: Pos(b.start), ValueEnd: Pos(end), Value: b.value.String()})
+	b.start = -1
+	b.value.Reset()
+}
+
+// wordParts parses the parts of an unquoted word, up to the first unquoted character for which stop is true.
+func (p *parser) wordParts(stop func(byte) bool) []WordPart {
+	var parts []WordPart
+	lit := newLitBuilder(&parts)
+	for !p.eof() && !stop(p.peek()) {
+		switch p.peek() {
+		case '\\':
+			switch {
+			case p.pos+1 == len(p.src):
+				lit.add(p.pos, `\`)
+				p.pos++
+			case p.src[p.pos+1] == '\n':
+				p.pos += 2
+			default:
+				_, size := utf8.DecodeRuneInString(p.src[p.pos+1:])
+				lit.add(p.pos, p.src[p.pos+1:p.pos+1+size])
+				p.pos += 1 + size
+			}
+		case '\'':
+			lit.flush(p.pos)
+			parts = append(parts, p.sglQuoted())
+		case '"':
+			lit.flush(p.pos)
+			parts = append(parts, p.dblQuoted())
+		case '`':
+			lit.flush(p.pos)
+			parts = append(parts, p.backquotes())
+		case '$':
+			pos := p.pos
+			if part := p.dollar(); part != nil {
+				lit.flush(pos)
+				parts = append(parts, part)
+			} else {
+				lit.add(p.pos, "$")
+				p.pos++
+			}
+		default:
+			lit.add(p.pos, p.src[p.pos:p.pos+1])
+			p.pos++
+		}
+	}
+	lit.flush(p.pos)
+	return parts
+}
+
+func (p *parser) sglQuoted() *SglQuoted {
+	left := p.pos
+	i := strings.IndexByte(p.src[left+1:], '\'')
+	if i == -1 {
+		p.errorf(left, "unterminated single-quoted string")
+	}
+	right := left + 1 + i
+	p.pos = right + 1
+	return &SglQuoted{Left: Pos(left), Right: Pos(right), Value: p.src[left+1 : right]}
+}
+
+func (p *parser) dblQuoted() *DblQuoted {
+	left := p.pos
+	p.pos++
+	var parts []WordPart
+	lit := newLitBuilder(&parts)
+	for {
+		if p.eof() {
+			p.errorf(left, "unterminated double-quoted string")
+		}
+		switch p.peek() {
+		case '"':
+			lit.flush(p.pos)
+			right := p.pos
+			p.pos++
+			return &DblQuoted{Left: Pos(left), Right: Pos(right), Parts: parts}
+		case '\\':
+			if p.pos+1 < len(p.src) && strings.IndexByte("$`\"\\\n", p.src[p.pos+1]) != -1 {
+				if p.src[p.pos+1] != '\n' {
+					lit.add(p.pos, p.src[p.pos+1:p.pos+2])
+				}
+				p.pos += 2
+			} else {
+				lit.add(p.pos, `\`)
+				p.pos++
+			}
+		case '`':
+			lit.flush(p.pos)
+			parts = append(parts, p.backquotes())
+		case '$':
+			pos := p.pos
+			if part := p.dollar(); part != nil {
+				lit.flush(pos)
+				parts = append(parts, part)
+			} else {
+				lit.add(p.pos, "$")
+				p.pos++
+			}
+		default:
+			lit.add(p.pos, p.src[p.pos:p.pos+1])
+			p.pos++
+		}
+	}
+}
+
+// backquotes parses a legacy “ `...` “ command substitution.
+func (p *parser) backquotes() *CmdSubst {
+	left := p.pos
+	right := -1
+	for i := left + 1; i < len(p.src); i++ {
+		if p.src[i] == '\\' {
+			i++
+		} else if p.src[i] == '`' {
+			right = i
+			break
+		}
+	}
+	if right == -1 {
+		p.errorf(left, "unterminated command substitution")
+	}
+	inner := &parser{src: p.src[:right], pos: left + 1}
+	stmts := inner.stmtList()
+	if !inner.eof() {
+		inner.errorf(inner.pos, "unexpected %s", inner.found())
+	}
+	p.pos = right + 1
+	return &CmdSubst{Left: Pos(left), Right: Pos(right), Stmts: stmts, Backquotes: true}
+}
+
+// dollar parses an expansion starting with `$`, returning nil if the `$` is literal.
+func (p *parser) dollar() WordPart {
+	rest := p.src[p.pos+1:]
+	switch {
+	case strings.HasPrefix(rest, "(("):
+		return p.arithmExp()
+	case strings.HasPrefix(rest, "("):
+		return p.cmdSubst()
+	case strings.HasPrefix(rest, "{"):
+		return p.paramExp()
+	}
+	n := paramLen(rest, false)
+	if n == 0 {
+		return nil
+	}
+	dollar := p.pos
+	p.pos += 1 + n
+	return &ParamExp{
+		Dollar: Pos(dollar),
+		Short:  true,
+		Param:  &Lit{ValuePos: Pos(dollar + 1), ValueEnd: Pos(p.pos), Value: rest[:n]},
+	}
+}
+
+func (p *parser) arithmExp() *ArithmExp {
+	left := p.pos
+	depth := 0
+	for i := left + 3; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			} else if strings.HasPrefix(p.src[i:], "))") {
+				p.pos = i + 2
+				return &ArithmExp{Left: Pos(left), Right: Pos(i), Expr: p.src[left+3 : i]}
+			}
+		}
+	}
+	p.errorf(left, "unterminated arithmetic expansion")
+	return nil
+}
+
+func (p *parser) cmdSubst() *CmdSubst {
+	left := p.pos
+	p.pos += len("$(")
+	stmts := p.stmtList(")")
+	p.skipLinebreaks()
+	if p.peek() != ')' {
+		p.errorf(left, "unterminated command substitution")
+	}
+	right := p.pos
+	p.pos++
+	return &CmdSubst{Left: Pos(left), Right: Pos(right), Stmts: stmts}
+}
+
+// paramOperators are the operators of braced parameter expansions, longest first.
+var paramOperators = []string{":-", ":=", ":?", ":+", "-", "=", "?", "+", "##", "#", "%%", "%", "//", "/"}
+
+func (p *parser) paramExp() *ParamExp {
+	pe := &ParamExp{Dollar: Pos(p.pos)}
+	p.pos += len("${")
+	if p.peek() == '#' {
+		if n := paramLen(p.src[p.pos+1:], true); n > 0 && strings.HasPrefix(p.src[p.pos+1+n:], "}") {
+			pe.Length = true
+			p.pos++
+		}
+	}
+	n := paramLen(p.src[p.pos:], true)
+	if n == 0 {
+		p.errorf(int(pe.Dollar), "bad substitution")
+	}
+	pe.Param = &Lit{ValuePos: Pos(p.pos), ValueEnd: Pos(p.pos + n), Value: p.src[p.pos : p.pos+n]}
+	p.pos += n
+
+	if !pe.Length {
+		for _, op := range paramOperators {
+			if p.hasPrefix(op) {
+				pe.Op = op
+				p.pos += len(op)
+				break
+			}
+		}
+	}
+	switch pe.Op {
+	case "":
+	case "/", "//":
+		pe.Arg = p.paramWord(func(c byte) bool { return c == '/' || c == '}' })
+		if p.peek() == '/' {
+			p.pos++
+			pe.Repl = p.paramWord(func(c byte) bool { return c == '}' })
+		}
+	default:
+		pe.Arg = p.paramWord(func(c byte) bool { return c == '}' })
+	}
+	if p.peek() != '}' {
+		if p.eof() {
+			p.errorf(int(pe.Dollar), "unterminated parameter expansion")
+		}
+		p.errorf(int(pe.Dollar), "bad substitution")
+	}
+	pe.Rbrace = Pos(p.pos)
+	p.pos++
+	return pe
+}
+
+// paramWord parses the word following the operator of a parameter expansion, nil if it is empty.
+func (p *parser) paramWord(stop func(byte) bool) *Word {
+	parts := p.wordParts(stop)
+	if len(parts) == 0 {
+		return nil
+	}
+	return &Word{Parts: parts}
+}
+
+// nameLen is the length of the variable name at the start of s, 0 if there is none.
+func nameLen(s string) int {
+	if s == "" || !isNameStart(s[0]) {
+		return 0
+	}
+	n := 1
+	for n < len(s) && (isNameStart(s[n]) || isDigit(s[n])) {
+		n++
+	}
+	return n
+}
+
+// paramLen is the length of the parameter at the start of s, i.e. a variable name, a positional parameter or a special parameter,
+// 0 if there is none. Only braced positional parameters may have more than one digit, e.g. `${10}`.
+func paramLen(s string, braced bool) int {
+	switch {
+	case s == "":
+		return 0
+	case isDigit(s[0]):
+		n := 1
+		for braced && n < len(s) && isDigit(s[n]) {
+			n++
+		}
+		return n
+	case strings.IndexByte("@*#?-$!", s[0]) != -1:
+		return 1
+	}
+	return nameLen(s)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,311 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// dump renders the structure of the syntax tree as an S-expression, with words as written in src.
+func dump(src string, node Node) string {
+	text := func(n Node) string { return src[n.Pos():n.End()] }
+	stmts := func(stmts []*Stmt) string {
+		var out []string
+		for _, stmt := range stmts {
+			out = append(out, dump(src, stmt))
+		}
+		return "[" + strings.Join(out, " ") + "]"
+	}
+	redirs := func(redirs []*Redirect) string {
+		var out string
+		for _, r := range redirs {
+			out += " " + dump(src, r)
+		}
+		return out
+	}
+	switch n := node.(type) {
+	case *Script:
+		return stmts(n.Stmts)
+	case *Stmt:
+		if n.Background {
+			return "(& " + dump(src, n.Cmd) + ")"
+		}
+		return dump(src, n.Cmd)
+	case *CallExpr:
+		var out []string
+		for _, a := range n.Assigns {
+			out = append(out, dump(src, a))
+		}
+		for _, w := range n.Args {
+			out = append(out, text(w))
+		}
+		return "(call " + strings.Join(out, " ") + redirs(n.Redirs) + ")"
+	case *Assign:
+		value := "<nil>"
+		if n.Value != nil {
+			value = text(n.Value)
+		}
+		return "(= " + n.Name.Value + " " + value + ")"
+	case *Redirect:
+		return "(redir " + text(n) + ")"
+	case *BinaryCmd:
+		return fmt.Sprintf("(%s %s %s)", n.Op, dump(src, n.X), dump(src, n.Y))
+	case *Pipeline:
+		var out []string
+		if n.Negated() {
+			out = append(out, "!")
+		}
+		for _, cmd := range n.Cmds {
+			out = append(out, dump(src, cmd))
+		}
+		return "(| " + strings.Join(out, " ") + ")"
+	case *Subshell:
+		return "(subshell " + stmts(n.Stmts) + redirs(n.Redirs) + ")"
+	case *Block:
+		return "(block " + stmts(n.Stmts) + redirs(n.Redirs) + ")"
+	case *IfClause:
+		return "(if " + stmts(n.Cond) + " " + stmts(n.Then) + " " + stmts(n.Else) + redirs(n.Redirs) + ")"
+	case *WhileClause:
+		kw := "while"
+		if n.Until {
+			kw = "until"
+		}
+		return "(" + kw + " " + stmts(n.Cond) + " " + stmts(n.Do) + redirs(n.Redirs) + ")"
+	case *ForClause:
+		items := "<nil>"
+		if n.Items != nil {
+			var out []string
+			for _, w := range n.Items {
+				out = append(out, text(w))
+			}
+			items = "[" + strings.Join(out, " ") + "]"
+		}
+		return "(for " + n.Name.Value + " " + items + " " + stmts(n.Do) + redirs(n.Redirs) + ")"
+	case *CaseClause:
+		var out []string
+		for _, item := range n.Items {
+			var patterns []string
+			for _, w := range item.Patterns {
+				patterns = append(patterns, text(w))
+			}
+			out = append(out, "("+strings.Join(patterns, "|")+" "+stmts(item.Stmts)+")")
+		}
+		return "(case " + text(n.Word) + " " + strings.Join(out, " ") + redirs(n.Redirs) + ")"
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		src      string
+		expected string
+	}{
+		{
+			desc:     "lists and pipelines",
+			src:      "apt-get update && apt-get install -y foo | tee log || exit 1",
+			expected: "[(|| (&& (call apt-get update) (| (call apt-get install -y foo) (call tee log))) (call exit 1))]",
+		},
+		{
+			desc:     "separators",
+			src:      "a; b & c\n\n# comment\nd ;",
+			expected: "[(call a) (& (call b)) (call c) (call d)]",
+		},
+		{
+			desc:     "continuations",
+			src:      "make \\\n  install &&\n  true",
+			expected: "[(&& (call make install) (call true))]",
+		},
+		{
+			desc:     "assignments and redirections",
+			src:      `DEBIAN_FRONTEND=noninteractive EMPTY= apt-get install -y curl >/dev/null 2>&1 <<-"EOF"`,
+			expected: `[(call (= DEBIAN_FRONTEND noninteractive) (= EMPTY <nil>) apt-get install -y curl (redir >/dev/null) (redir 2>&1) (redir <<-"EOF"))]`,
+		},
+		{
+			desc:     "assignment only",
+			src:      "A=1 B=$A",
+			expected: "[(call (= A 1) (= B $A))]",
+		},
+		{
+			desc:     "assignment-like argument",
+			src:      "env A=1",
+			expected: "[(call env A=1)]",
+		},
+		{
+			desc:     "negation, subshell and block",
+			src:      "! (cd /src && make) | { cat; echo; } > log",
+			expected: "[(| ! (subshell [(&& (call cd /src) (call make))]) (block [(call cat) (call echo)] (redir > log)))]",
+		},
+		{
+			desc:     "if",
+			src:      `if [ -f x ]; then rm x; elif test -d x; then rmdir x; else touch "x"; fi 2>/dev/null`,
+			expected: `[(if [(call [ -f x ])] [(call rm x)] [(if [(call test -d x)] [(call rmdir x)] [(call touch "x")])] (redir 2>/dev/null))]`,
+		},
+		{
+			desc:     "while and until",
+			src:      "while read -r line; do echo \"$line\"; done < file; until false\ndo :; done",
+			expected: `[(while [(call read -r line)] [(call echo "$line")] (redir < file)) (until [(call false)] [(call :)])]`,
+		},
+		{
+			desc:     "for",
+			src:      `for f in *.txt "a b"; do cat "$f"; done; for arg; do :; done; for x in; do :; done`,
+			expected: `[(for f [*.txt "a b"] [(call cat "$f")]) (for arg <nil> [(call :)]) (for x [] [(call :)])]`,
+		},
+		{
+			desc:     "case",
+			src:      `case "$(uname -m)" in x86_64|amd64) echo amd64 ;; (aarch64) echo arm64;; *) ;; esac`,
+			expected: `[(case "$(uname -m)" (x86_64|amd64 [(call echo amd64)]) (aarch64 [(call echo arm64)]) (* []))]`,
+		},
+		{
+			desc:     "reserved words as arguments",
+			src:      "echo if then fi }",
+			expected: "[(call echo if then fi })]",
+		},
+		{
+			desc:     "words",
+			src:      `echo a#b 'x  y'"$HOME"\ z ${V:-"d e"} $(date) ` + "`date`" + ` $((1 + (2 * 3))) $ é`,
+			expected: `[(call echo a#b 'x  y'"$HOME"\ z ${V:-"d e"} $(date) ` + "`date`" + ` $((1 + (2 * 3))) $ é)]`,
+		},
+		{
+			desc:     "empty",
+			src:      " \n# only a comment",
+			expected: "[]",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			script, err := Parse(tc.src)
+			if err != nil {
+				t.Fatalf("Parse() error'd: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, dump(tc.src, script)); diff != "" {
+				t.Error("mismatch (-want +got):\n", diff)
+			}
+		})
+	}
+}
+
+func TestParseWordParts(t *testing.T) {
+	src := `pre"a\"b$x${y:-d}$(c)"'s'\$z${#n}${p/a b/c}$1`
+	script, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	word := script.Stmts[0].Cmd.(*CallExpr).Args[0]
+	if word.Pos() != 0 || int(word.End()) != len(src) {
+		t.Errorf("word spans [%d, %d), want [0, %d)", word.Pos(), word.End(), len(src))
+	}
+	var got []string
+	for _, part := range word.Parts {
+		got = append(got, fmt.Sprintf("%T %s", part, src[part.Pos():part.End()]))
+	}
+	expected := []string{
+		"*shell.Lit pre",
+		`*shell.DblQuoted "a\"b$x${y:-d}$(c)"`,
+		"*shell.SglQuoted 's'",
+		`*shell.Lit \$z`,
+		"*shell.ParamExp ${#n}",
+		"*shell.ParamExp ${p/a b/c}",
+		"*shell.ParamExp $1",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Error("parts mismatch (-want +got):\n", diff)
+	}
+
+	dq := word.Parts[1].(*DblQuoted)
+	if lit := dq.Parts[0].(*Lit); lit.Value != `a"b` {
+		t.Errorf("double-quoted literal = %q, want %q", lit.Value, `a"b`)
+	}
+	if lit := word.Parts[3].(*Lit); lit.Value != "$z" {
+		t.Errorf("escaped literal = %q, want %q", lit.Value, "$z")
+	}
+	if pe := dq.Parts[2].(*ParamExp); pe.Param.Value != "y" || pe.Op != ":-" || src[pe.Arg.Pos():pe.Arg.End()] != "d" {
+		t.Errorf("${y:-d} = %+v", pe)
+	}
+	if pe := word.Parts[4].(*ParamExp); !pe.Length || pe.Param.Value != "n" || pe.Op != "" {
+		t.Errorf("${#n} = %+v", pe)
+	}
+	if pe := word.Parts[5].(*ParamExp); pe.Op != "/" || src[pe.Arg.Pos():pe.Arg.End()] != "a b" || src[pe.Repl.Pos():pe.Repl.End()] != "c" {
+		t.Errorf("${p/a b/c} = %+v", pe)
+	}
+	if _, ok := word.Lit(); ok {
+		t.Error("Lit() of a word with expansions = ok, want !ok")
+	}
+
+	lit, ok := (&Word{Parts: []WordPart{
+		&Lit{Value: "a b"},
+		&SglQuoted{Value: "$c"},
+		&DblQuoted{Parts: []WordPart{&Lit{Value: "d"}}},
+	}}).Lit()
+	if !ok || lit != "a b$cd" {
+		t.Errorf("Lit() = %q, %v, want %q, true", lit, ok, "a b$cd")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		src string
+		pos Pos
+		msg string
+	}{
+		{src: `echo "a b`, pos: 5, msg: "unterminated double-quoted string"},
+		{src: `echo 'a b`, pos: 5, msg: "unterminated single-quoted string"},
+		{src: "echo $(date", pos: 5, msg: "unterminated command substitution"},
+		{src: "echo `date", pos: 5, msg: "unterminated command substitution"},
+		{src: "echo ${a", pos: 5, msg: "unterminated parameter expansion"},
+		{src: "echo ${a b}", pos: 5, msg: "bad substitution"},
+		{src: "echo $((1 + 2)", pos: 5, msg: "unterminated arithmetic expansion"},
+		{src: "make &&", pos: 7, msg: "expected a command, found end of script"},
+		{src: "make | | cat", pos: 7, msg: "expected a command, found `|`"},
+		{src: "make >", pos: 6, msg: "expected a word after `>`, found end of script"},
+		{src: "(make", pos: 5, msg: "expected `)` to close the subshell at offset 0, found end of script"},
+		{src: "make )", pos: 5, msg: "unexpected `)`"},
+		{src: "if true; then :; done", pos: 17, msg: "unexpected `done`"},
+		{src: "if true; then :", pos: 15, msg: "expected `fi`, found end of script"},
+		{src: "while true; do done", pos: 15, msg: "expected a command, found `done`"},
+		{src: "for 1 in a; do :; done", pos: 4, msg: "expected a variable name after `for`, found `1`"},
+		{src: "case x in a) :;;", pos: 16, msg: "expected `esac`, found end of script"},
+		{src: "fi", pos: 0, msg: "unexpected `fi`"},
+		{src: "a;; b", pos: 1, msg: "unexpected `;`"},
+		{src: "f() { :; }", pos: 1, msg: "function definitions are not supported"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.src, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse(tc.src)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse() error = %v, want a *ParseError", err)
+			}
+			if pe.Pos != tc.pos || pe.Msg != tc.msg {
+				t.Errorf("Parse() error = %d: %q, want %d: %q", pe.Pos, pe.Msg, tc.pos, tc.msg)
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	src := "for f in $(ls); do sed -i s/a/b/ \"$f\" && git add \"$f\"; done | tee log"
+	script, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	var names []string
+	Walk(script, func(node Node) bool {
+		if call, ok := node.(*CallExpr); ok {
+			name, _ := call.Name()
+			names = append(names, name)
+		}
+		_, isCmdSubst := node.(*CmdSubst)
+		return !isCmdSubst
+	})
+	if diff := cmp.Diff([]string{"sed", "git", "tee"}, names); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
@@ -0,0 +1,116 @@
+package shell
+
+/*
+Walk traverses the syntax tree rooted at node in depth-first order, calling fn for each node, e.g. to find every command of a script:
+
+	Walk(script, func(node Node) bool {
+		if call, ok := node.(*CallExpr); ok {
+			name, _ := call.Name()
+			fmt.Println(name)
+		}
+		return true
+	})
+
+The children of a node are not traversed if fn returns false.
+*/
+func Walk(node Node, fn func(Node) bool) {
+	if !fn(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *Script:
+		walkStmts(n.Stmts, fn)
+	case *Stmt:
+		Walk(n.Cmd, fn)
+	case *CallExpr:
+		for _, a := range n.Assigns {
+			Walk(a, fn)
+		}
+		walkWords(n.Args, fn)
+		walkRedirs(n.Redirs, fn)
+	case *BinaryCmd:
+		Walk(n.X, fn)
+		Walk(n.Y, fn)
+	case *Pipeline:
+		for _, cmd := range n.Cmds {
+			Walk(cmd, fn)
+		}
+	case *Subshell:
+		walkStmts(n.Stmts, fn)
+		walkRedirs(n.Redirs, fn)
+	case *Block:
+		walkStmts(n.Stmts, fn)
+		walkRedirs(n.Redirs, fn)
+	case *IfClause:
+		walkStmts(n.Cond, fn)
+		walkStmts(n.Then, fn)
+		walkStmts(n.Else, fn)
+		walkRedirs(n.Redirs, fn)
+	case *WhileClause:
+		walkStmts(n.Cond, fn)
+		walkStmts(n.Do, fn)
+		walkRedirs(n.Redirs, fn)
+	case *ForClause:
+		Walk(n.Name, fn)
+		walkWords(n.Items, fn)
+		walkStmts(n.Do, fn)
+		walkRedirs(n.Redirs, fn)
+	case *CaseClause:
+		Walk(n.Word, fn)
+		for _, item := range n.Items {
+			Walk(item, fn)
+		}
+		walkRedirs(n.Redirs, fn)
+	case *CaseItem:
+		walkWords(n.Patterns, fn)
+		walkStmts(n.Stmts, fn)
+	case *Assign:
+		Walk(n.Name, fn)
+		if n.Value != nil {
+			Walk(n.Value, fn)
+		}
+	case *Redirect:
+		if n.N != nil {
+			Walk(n.N, fn)
+		}
+		Walk(n.Word, fn)
+	case *Word:
+		walkParts(n.Parts, fn)
+	case *DblQuoted:
+		walkParts(n.Parts, fn)
+	case *ParamExp:
+		Walk(n.Param, fn)
+		if n.Arg != nil {
+			Walk(n.Arg, fn)
+		}
+		if n.Repl != nil {
+			Walk(n.Repl, fn)
+		}
+	case *CmdSubst:
+		walkStmts(n.Stmts, fn)
+	}
+}
+
+func walkStmts(stmts []*Stmt, fn func(Node) bool) {
+	for _, stmt := range stmts {
+		Walk(stmt, fn)
+	}
+}
+
+func walkWords(words []*Word, fn func(Node) bool) {
+	for _, w := range words {
+		Walk(w, fn)
+	}
+}
+
+func walkRedirs(redirs []*Redirect, fn func(Node) bool) {
+	for _, r := range redirs {
+		Walk(r, fn)
+	}
+}
+
+func walkParts(parts []WordPart, fn func(Node) bool) {
+	for _, part := range parts {
+		Walk(part, fn)
+	}
+}
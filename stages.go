@@ -0,0 +1,329 @@
+package dockerfile
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+)
+
+// Stage is a build stage, i.e. a FROM instruction and the statements which follow it up to the next FROM.
+type Stage struct {
+	// Index is the position of the stage among all of the stages of the Dockerfile, starting at 0.
+	Index int
+	// From is the instruction which starts the stage.
+	From *statement.FromInstruction
+	// Statements are all of the statements of the stage, starting with From.
+	Statements []statement.Statement
+	// Base is the earlier stage which this stage is built from, nil if it is built from an image.
+	Base *Stage
+
+	// earlier are the stages preceding this one.
+	earlier []*Stage
+}
+
+// Name is the alias of the stage, or its index if it has none.
+func (s *Stage) Name() string {
+	if s.From.Alias != "" {
+		return s.From.Alias
+	}
+	return strconv.Itoa(s.Index)
+}
+
+// Stages are the build stages of the Dockerfile, in order of appearance.
+// Statements preceding the first FROM instruction, i.e. global ARGs, are not part of any stage.
+func (p *Parsed) Stages() []*Stage {
+	var stages []*Stage
+	for _, stmt := range p.Statements {
+		if from, isFROM := stmt.(*statement.FromInstruction); isFROM {
+			stage := &Stage{
+				Index:   len(stages),
+				From:    from,
+				earlier: stages[:len(stages):len(stages)],
+			}
+			stage.Base = stage.earlierStage(from.Image, false)
+			stages = append(stages, stage)
+		}
+		if len(stages) > 0 {
+			stage := stages[len(stages)-1]
+			stage.Statements = append(stage.Statements, stmt)
+		}
+	}
+	return stages
+}
+
+// earlierStage is the last of the preceding stages with the given name, or index if allowed, nil if there is none.
+func (s *Stage) earlierStage(name string, byIndex bool) *Stage {
+	for i := len(s.earlier) - 1; i >= 0; i-- {
+		if alias := s.earlier[i].From.Alias; alias != "" && strings.EqualFold(alias, name) {
+			return s.earlier[i]
+		}
+	}
+	if byIndex {
+		if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(s.earlier) {
+			return s.earlier[i]
+		}
+	}
+	return nil
+}
+
+// ScratchImage is the name of the empty image, which stages may be built from.
+const ScratchImage = "scratch"
+
+// SourceKind is the kind of thing which a FROM instruction, or the `--from` flag of a COPY instruction, refers to.
+type SourceKind string
+
+const (
+	// ContextSource is the build context, which COPY instructions without `--from` copy from.
+	ContextSource SourceKind = "context"
+	// StageSource is an earlier build stage.
+	StageSource SourceKind = "stage"
+	// ScratchSource is the empty image, see ScratchImage.
+	ScratchSource SourceKind = "scratch"
+	// ImageSource is an external image.
+	ImageSource SourceKind = "image"
+)
+
+// Source is what a FROM instruction, or the `--from` flag of a COPY instruction, refers to.
+type Source struct {
+	Kind SourceKind
+	// Stage is the build stage, set if Kind is StageSource.
+	Stage *Stage
+	// Image is the image as written, set if Kind is ImageSource. See statement.ImageReference.Normalized.
+	Image statement.ImageReference
+}
+
+// BaseSource is what the stage is built from: an earlier stage, scratch, or an external image.
+// It fails if the image is not a valid reference, e.g. because it contains variables which have not been resolved, see Resolve.
+func (s *Stage) BaseSource() (Source, error) {
+	return s.source(s.From.Image, false)
+}
+
+// CopySource is what the COPY instruction of the stage copies from: the build context, an earlier stage, or an external image.
+// Stages may be referred to by name or index. It fails if the image is not a valid reference.
+func (s *Stage) CopySource(c *statement.CopyInstruction) (Source, error) {
+	if c.From == "" {
+		return Source{Kind: ContextSource}, nil
+	}
+	return s.source(c.From, true)
+}
+
+func (s *Stage) source(name string, byIndex bool) (Source, error) {
+	if stage := s.earlierStage(name, byIndex); stage != nil {
+		return Source{Kind: StageSource, Stage: stage}, nil
+	}
+	if name == ScratchImage {
+		return Source{Kind: ScratchSource}, nil
+	}
+	ref, err := statement.ParseImageReference(name)
+	if err != nil {
+		return Source{}, err
+	}
+	return Source{Kind: ImageSource, Image: ref}, nil
+}
+
+// Dependencies are the earlier stages which the stage needs in order to be built: its base stage, the stages which its
+// COPY instructions copy from, and the stages which its RUN instructions mount, in order of first reference.
+// Stages may be referred to by name, or by index other than by FROM.
+func (s *Stage) Dependencies() []*Stage {
+	var deps []*Stage
+	seen := map[*Stage]bool{}
+	depend := func(stage *Stage) {
+		if stage != nil && !seen[stage] {
+			seen[stage] = true
+			deps = append(deps, stage)
+		}
+	}
+	depend(s.Base)
+	for _, stmt := range s.Statements {
+		switch inst := stmt.(type) {
+		case *statement.CopyInstruction:
+			if inst.From != "" {
+				depend(s.earlierStage(inst.From, true))
+			}
+		case *statement.RunInstruction:
+			for _, m := range inst.Mounts {
+				if from := mountFrom(m); from != "" {
+					depend(s.earlierStage(from, true))
+				}
+			}
+		}
+	}
+	return deps
+}
+
+// mountFrom is the build stage or image which the mount is taken from, empty if it is not.
+func mountFrom(m statement.Mount) string {
+	switch m := m.(type) {
+	case *statement.BindMount:
+		return m.From
+	case *statement.CacheMount:
+		return m.From
+	}
+	return ""
+}
+
+// ExposedPorts is the set of ports exposed by the stage, including those inherited from its base stage.
+// Ports of EXPOSE instructions which have not been resolved are not included, see Resolve.
+func (s *Stage) ExposedPorts() map[statement.Port]bool {
+	ports := map[statement.Port]bool{}
+	if s.Base != nil {
+		for port := range s.Base.ExposedPorts() {
+			ports[port] = true
+		}
+	}
+	for _, stmt := range s.Statements {
+		if expose, isEXPOSE := stmt.(*statement.ExposeInstruction); isEXPOSE {
+			for _, spec := range expose.Ports {
+				for _, port := range spec.Ports() {
+					ports[port] = true
+				}
+			}
+		}
+	}
+	return ports
+}
+
+// DefaultShell is the shell which runs shell-form commands on Linux unless overridden by a SHELL instruction.
+var DefaultShell = []string{"/bin/sh", "-c"}
+
+// ShellCommand is a shell-form command and the shell which runs it.
+type ShellCommand struct {
+	// Instruction is the RUN, CMD, ENTRYPOINT or HEALTHCHECK instruction with a shell-form command.
+	Instruction statement.Instruction
+	// Shell is the executable and arguments which the command is appended to.
+	Shell []string
+}
+
+// Shell is the shell in effect at the end of the stage, inherited from its base stage unless overridden by a SHELL instruction.
+// Stages built from an image are assumed to start with DefaultShell.
+func (s *Stage) Shell() []string {
+	return s.shellAt(len(s.Statements))
+}
+
+// ShellCommands are the shell-form commands of the stage, in order of appearance, with the shell which runs each of them.
+func (s *Stage) ShellCommands() []ShellCommand {
+	var commands []ShellCommand
+	for i, stmt := range s.Statements {
+		if inst, isShellForm := shellFormInstruction(stmt); isShellForm {
+			commands = append(commands, ShellCommand{Instruction: inst, Shell: s.shellAt(i)})
+		}
+	}
+	return commands
+}
+
+// shellAt is the shell in effect for the i-th statement of the stage.
+func (s *Stage) shellAt(i int) []string {
+	for j := i - 1; j >= 0; j-- {
+		if shell, isSHELL := s.Statements[j].(*statement.ShellInstruction); isSHELL {
+			return shell.Shell
+		}
+	}
+	if s.Base != nil {
+		return s.Base.shellAt(len(s.Base.Statements))
+	}
+	return DefaultShell
+}
+
+// Command is the shell-form command as written, minus line continuations, which is passed to the shell.
+func (c ShellCommand) Command() string {
+	command, _ := c.source()
+	return command
+}
+
+// Syntax parses the command into a shell syntax tree, see shell.Parse.
+// If the command is a single heredoc, e.g. `RUN <<EOF`, the body of the heredoc is the script which is parsed instead.
+// Positions within the tree, and of any *shell.ParseError, are offsets within Command, or within the body of the
+// heredoc, see Position.
+func (c ShellCommand) Syntax() (*shell.Script, error) {
+	if heredoc, isScript := c.heredocScript(); isScript {
+		return shell.Parse(heredoc.Body)
+	}
+	return shell.Parse(c.Command())
+}
+
+// Position maps an offset within Command, or within the body of a heredoc script, back to the original Dockerfile.
+// It is the zero Position if the instruction was not parsed, or has been modified since.
+// Columns within the body of a `<<-` heredoc do not count the leading tabs which were stripped.
+func (c ShellCommand) Position(pos shell.Pos) statement.Position {
+	if heredoc, isScript := c.heredocScript(); isScript {
+		return heredocPosition(c.Instruction.Location(), heredoc, int(pos))
+	}
+	_, offset := c.source()
+	if offset == -1 {
+		return statement.Position{}
+	}
+	return c.argumentsMap().Position(offset + int(pos))
+}
+
+// heredocScript is the heredoc whose body is run as the script of a RUN instruction, e.g. `RUN <<EOF`,
+// and whether there is one.
+func (c ShellCommand) heredocScript() (statement.Heredoc, bool) {
+	run, isRUN := c.Instruction.(*statement.RunInstruction)
+	if !isRUN || len(run.Heredocs) != 1 {
+		return statement.Heredoc{}, false
+	}
+	heredoc := run.Heredocs[0]
+	return heredoc, strings.TrimSpace(run.Command) == heredoc.Delimiter
+}
+
+// heredocPosition maps an offset within the body of the last heredoc of a statement back to the original Dockerfile.
+// The body is followed by the line terminating the heredoc, which is the last line of the statement.
+func heredocPosition(loc statement.Range, heredoc statement.Heredoc, offset int) statement.Position {
+	if loc.End.Line == 0 || offset < 0 || offset > len(heredoc.Body) {
+		return statement.Position{}
+	}
+	preceding := heredoc.Body[:offset]
+	return statement.Position{
+		Line:   loc.End.Line - strings.Count(heredoc.Body, "\n") + strings.Count(preceding, "\n"),
+		Column: offset - strings.LastIndex(preceding, "\n"),
+	}
+}
+
+func (c ShellCommand) argumentsMap() statement.SourceMap {
+	if m, ok := c.Instruction.(interface{ ArgumentsMap() statement.SourceMap }); ok {
+		return m.ArgumentsMap()
+	}
+	return statement.SourceMap{}
+}
+
+// source locates the command within the arguments of its instruction as written,
+// returning it along with its offset within the arguments, or -1 if it cannot be located.
+func (c ShellCommand) source() (string, int) {
+	var words []string
+	switch inst := c.Instruction.(type) {
+	case *statement.RunInstruction:
+		words = []string{inst.Command}
+	case *statement.HealthcheckInstruction:
+		words = inst.Cmd.Args.List
+	default:
+		words = c.Instruction.Arguments().List
+	}
+	// The words are a suffix of the arguments, e.g. following the flags or HEALTHCHECK's `CMD`.
+	args := strings.TrimRightFunc(c.argumentsMap().Text, unicode.IsSpace)
+	offset := len(args)
+	for i := len(words) - 1; i >= 0; i-- {
+		j := strings.LastIndex(args[:offset], words[i])
+		if j == -1 || (i < len(words)-1 && strings.TrimSpace(args[j+len(words[i]):offset]) != "") ||
+			(i == len(words)-1 && j+len(words[i]) != len(args)) {
+			return strings.Join(words, " "), -1
+		}
+		offset = j
+	}
+	return args[offset:], offset
+}
+
+// shellFormInstruction is whether the statement is a command which is run by the shell, rather than exec'd directly.
+func shellFormInstruction(stmt statement.Statement) (statement.Instruction, bool) {
+	switch inst := stmt.(type) {
+	case *statement.RunInstruction:
+		return inst, inst.Exec == nil
+	case *statement.HealthcheckInstruction:
+		return inst, inst.Cmd != nil && !inst.Cmd.Args.Execable
+	case *statement.GenericInstruction:
+		return inst, (inst.Type() == statement.CMD || inst.Type() == statement.ENTRYPOINT) && !inst.Args.Execable
+	}
+	return nil, false
+}
@@ -0,0 +1,324 @@
+package dockerfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dekkagaijin/go-dockerfile/shell"
+	"github.com/dekkagaijin/go-dockerfile/statement"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStages(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"ARG BASE=alpine",
+		"FROM golang AS build",
+		"RUN go build",
+		"FROM build AS test",
+		"RUN go test",
+		"FROM ${BASE}",
+		"COPY --from=build /app /app",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	stages := parsed.Stages()
+	if len(stages) != 3 {
+		t.Fatalf("got %d stages, want 3", len(stages))
+	}
+	for i, want := range []struct {
+		name       string
+		statements int
+		base       *Stage
+	}{
+		{name: "build", statements: 2},
+		{name: "test", statements: 2, base: stages[0]},
+		{name: "2", statements: 2},
+	} {
+		stage := stages[i]
+		if stage.Index != i || stage.Name() != want.name || len(stage.Statements) != want.statements || stage.Base != want.base {
+			t.Errorf("stage %d = {Index: %d, Name: %q, %d statements, Base: %v}, want {Name: %q, %d statements, Base: %v}",
+				i, stage.Index, stage.Name(), len(stage.Statements), stage.Base, want.name, want.statements, want.base)
+		}
+		if stage.Statements[0] != stage.From {
+			t.Errorf("stage %d does not start with its FROM instruction", i)
+		}
+	}
+}
+
+func TestStageExposedPorts(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM image AS base",
+		"EXPOSE 80 53/udp",
+		"FROM base",
+		"EXPOSE 8000-8002/tcp 80/tcp ${PORT}",
+		"FROM image",
+		"EXPOSE 9000/sctp",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	stages := parsed.Stages()
+	expected := []map[statement.Port]bool{
+		{
+			{Number: 80, Protocol: statement.TCP}: true,
+			{Number: 53, Protocol: statement.UDP}: true,
+		},
+		{
+			{Number: 80, Protocol: statement.TCP}:   true,
+			{Number: 53, Protocol: statement.UDP}:   true,
+			{Number: 8000, Protocol: statement.TCP}: true,
+			{Number: 8001, Protocol: statement.TCP}: true,
+			{Number: 8002, Protocol: statement.TCP}: true,
+		},
+		{
+			{Number: 9000, Protocol: statement.SCTP}: true,
+		},
+	}
+	for i, want := range expected {
+		if diff := cmp.Diff(want, stages[i].ExposedPorts()); diff != "" {
+			t.Errorf("stage %d mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestStageShellCommands(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM image AS base",
+		"RUN echo default",
+		`SHELL ["/bin/bash", "-o", "pipefail", "-c"]`,
+		"RUN echo bash | cat",
+		`RUN ["echo", "exec"]`,
+		"FROM base",
+		"RUN echo inherited",
+		`SHELL ["powershell", "-Command"]`,
+		"HEALTHCHECK CMD Test-Path C:/ready",
+		`ENTRYPOINT ["app"]`,
+		"CMD Write-Host hello",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	stages := parsed.Stages()
+	bash := []string{"/bin/bash", "-o", "pipefail", "-c"}
+	powershell := []string{"powershell", "-Command"}
+	for i, want := range []struct {
+		lines  []int
+		shells [][]string
+		final  []string
+	}{
+		{lines: []int{2, 4}, shells: [][]string{DefaultShell, bash}, final: bash},
+		{lines: []int{7, 9, 11}, shells: [][]string{bash, powershell, powershell}, final: powershell},
+	} {
+		commands := stages[i].ShellCommands()
+		var lines []int
+		var shells [][]string
+		for _, cmd := range commands {
+			lines = append(lines, cmd.Instruction.Location().Start.Line)
+			shells = append(shells, cmd.Shell)
+		}
+		if diff := cmp.Diff(want.lines, lines); diff != "" {
+			t.Errorf("stage %d shell-form command lines mismatch (-want +got):\n%s", i, diff)
+		}
+		if diff := cmp.Diff(want.shells, shells); diff != "" {
+			t.Errorf("stage %d shells mismatch (-want +got):\n%s", i, diff)
+		}
+		if diff := cmp.Diff(want.final, stages[i].Shell()); diff != "" {
+			t.Errorf("stage %d final shell mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestShellCommandSyntax(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM image",
+		"RUN --network=none apt-get update \\",
+		"",
+		"    # refresh the index first",
+		"    && apt-get install -y  curl \\",
+		"  | tee log",
+		`CMD echo "a  b"   >&2`,
+		"HEALTHCHECK --retries=3 CMD curl -f http://localhost/ || exit 1",
+		"RUN <<EOF",
+		"apt-get update",
+		"  make install",
+		"EOF",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+
+	type call struct {
+		name string
+		pos  statement.Position
+	}
+	commands := parsed.Stages()[0].ShellCommands()
+	for i, want := range []struct {
+		command string
+		calls   []call
+	}{
+		{
+			command: "apt-get update && apt-get install -y  curl | tee log",
+			calls: []call{
+				{name: "apt-get", pos: statement.Position{Line: 2, Column: 20}},
+				{name: "apt-get", pos: statement.Position{Line: 5, Column: 8}},
+				{name: "tee", pos: statement.Position{Line: 6, Column: 5}},
+			},
+		},
+		{
+			command: `echo "a  b"   >&2`,
+			calls:   []call{{name: "echo", pos: statement.Position{Line: 7, Column: 5}}},
+		},
+		{
+			command: "curl -f http://localhost/ || exit 1",
+			calls: []call{
+				{name: "curl", pos: statement.Position{Line: 8, Column: 29}},
+				{name: "exit", pos: statement.Position{Line: 8, Column: 58}},
+			},
+		},
+		{
+			command: "<<EOF",
+			calls: []call{
+				{name: "apt-get", pos: statement.Position{Line: 10, Column: 1}},
+				{name: "make", pos: statement.Position{Line: 11, Column: 3}},
+			},
+		},
+	} {
+		cmd := commands[i]
+		if cmd.Command() != want.command {
+			t.Errorf("command %d = %q, want %q", i, cmd.Command(), want.command)
+		}
+		script, err := cmd.Syntax()
+		if err != nil {
+			t.Fatalf("command %d Syntax() error'd: %v", i, err)
+		}
+		var calls []call
+		shell.Walk(script, func(node shell.Node) bool {
+			if c, ok := node.(*shell.CallExpr); ok {
+				name, _ := c.Name()
+				calls = append(calls, call{name: name, pos: cmd.Position(c.Pos())})
+			}
+			return true
+		})
+		if diff := cmp.Diff(want.calls, calls, cmp.AllowUnexported(call{})); diff != "" {
+			t.Errorf("command %d calls mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestStageSources(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM golang:1.21 AS build",
+		"COPY . /src",
+		"FROM scratch AS Final",
+		"COPY --from=BUILD /app /app",
+		"COPY --from=0 /app /app",
+		"COPY --from=nginx:latest /etc/nginx /etc/nginx",
+		"COPY --from=final /app /app",
+		"FROM final",
+		"FROM ${BASE}",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+	stages := parsed.Stages()
+
+	type source struct {
+		kind  SourceKind
+		stage int
+		image string
+	}
+	describe := func(src Source) source {
+		s := source{kind: src.Kind, stage: -1, image: src.Image.String()}
+		if src.Stage != nil {
+			s.stage = src.Stage.Index
+		}
+		return s
+	}
+
+	var bases []source
+	for _, stage := range stages[:3] {
+		src, err := stage.BaseSource()
+		if err != nil {
+			t.Fatalf("stage %d BaseSource() error'd: %v", stage.Index, err)
+		}
+		bases = append(bases, describe(src))
+	}
+	expectedBases := []source{
+		{kind: ImageSource, stage: -1, image: "golang:1.21"},
+		{kind: ScratchSource, stage: -1},
+		{kind: StageSource, stage: 1},
+	}
+	if diff := cmp.Diff(expectedBases, bases, cmp.AllowUnexported(source{})); diff != "" {
+		t.Error("base sources mismatch (-want +got):\n", diff)
+	}
+	if _, err := stages[3].BaseSource(); err == nil {
+		t.Error("BaseSource() of an unresolved image = nil error, want an error")
+	}
+
+	var copies []source
+	for _, stage := range stages[:2] {
+		for _, stmt := range stage.Statements {
+			if c, ok := stmt.(*statement.CopyInstruction); ok {
+				src, err := stage.CopySource(c)
+				if err != nil {
+					t.Fatalf("CopySource(%q) error'd: %v", c.From, err)
+				}
+				copies = append(copies, describe(src))
+			}
+		}
+	}
+	expectedCopies := []source{
+		{kind: ContextSource, stage: -1},
+		{kind: StageSource, stage: 0},
+		{kind: StageSource, stage: 0},
+		{kind: ImageSource, stage: -1, image: "nginx:latest"},
+		// a stage cannot copy from itself
+		{kind: ImageSource, stage: -1, image: "final"},
+	}
+	if diff := cmp.Diff(expectedCopies, copies, cmp.AllowUnexported(source{})); diff != "" {
+		t.Error("copy sources mismatch (-want +got):\n", diff)
+	}
+}
+
+func TestStageDependencies(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"FROM golang:1.21 AS deps",
+		"FROM deps AS build",
+		"RUN --mount=type=cache,target=/root/.cache,from=cache --mount=type=bind,from=0,target=/deps go build",
+		"FROM alpine AS cache",
+		"FROM scratch AS final",
+		"COPY --from=build /app /app",
+		"COPY --from=build /lib /lib",
+		"COPY --from=alpine /etc/ssl /etc/ssl",
+		"COPY --from=1 /bin /bin",
+	}, "\n")
+	parsed, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("Parse() error'd: %v", err)
+	}
+
+	var deps [][]string
+	for _, stage := range parsed.Stages() {
+		names := []string{}
+		for _, dep := range stage.Dependencies() {
+			names = append(names, dep.Name())
+		}
+		deps = append(deps, names)
+	}
+	expected := [][]string{
+		{},
+		// the `cache` stage is only declared later, so is an image
+		{"deps"},
+		{},
+		{"build"},
+	}
+	if diff := cmp.Diff(expected, deps); diff != "" {
+		t.Error("mismatch (-want +got):\n", diff)
+	}
+}
@@ -1,6 +1,26 @@
 package statement
 
+// AddInstruction adds files from the build context, URLs or git repositories into the image.
+// See: https://docs.docker.com/engine/reference/builder/#add
 type AddInstruction struct {
+	Node
+
+	// Chown is the `<user>[:<group>]` which will own the added files (`--chown`).
+	Chown string
+	// Chmod is the permissions of the added files (`--chmod`).
+	Chmod string
+	// Checksum is the expected digest of a remote source (`--checksum`), e.g. `sha256:...`.
+	Checksum string
+	// KeepGitDir is whether the `.git` directory of a git repository source is preserved (`--keep-git-dir`).
+	KeepGitDir bool
+	// Link is whether the files are added into an independent layer (`--link`).
+	Link bool
+	// Exclude are the patterns of paths which are excluded from the copy (`--exclude`).
+	Exclude []string
+	// FlagOrder are the names of the flags in the order they were written, which Flags follows.
+	FlagOrder []string
+
+	// Args are the sources and destination, as written.
 	Args Arguments
 
 	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
@@ -12,8 +32,28 @@ func (*AddInstruction) Type() Type {
 	return ADD
 }
 
-func (*AddInstruction) Flags() map[string]string {
-	return nil
+// Flags are all of the flags of the instruction, in the order they were written, see FlagOrder.
+func (i *AddInstruction) Flags() []Flag {
+	var flags []Flag
+	if i.Chown != "" {
+		flags = append(flags, Flag{Name: "chown", Value: i.Chown})
+	}
+	if i.Chmod != "" {
+		flags = append(flags, Flag{Name: "chmod", Value: i.Chmod})
+	}
+	if i.Checksum != "" {
+		flags = append(flags, Flag{Name: "checksum", Value: i.Checksum})
+	}
+	if i.KeepGitDir {
+		flags = append(flags, Flag{Name: "keep-git-dir", IsBool: true})
+	}
+	if i.Link {
+		flags = append(flags, Flag{Name: "link", IsBool: true})
+	}
+	for _, pattern := range i.Exclude {
+		flags = append(flags, Flag{Name: "exclude", Value: pattern})
+	}
+	return orderFlags(flags, i.FlagOrder)
 }
 
 func (i *AddInstruction) Arguments() Arguments {
@@ -1,24 +1,58 @@
 package statement
 
+// ArgDeclaration declares a single build argument, e.g. `VERSION=1.0`.
+type ArgDeclaration struct {
+	Name string
+	// HasDefault is whether a default value was declared, which distinguishes `ARG X=` from `ARG X`.
+	HasDefault bool
+	// Default is the default value, with any quotes and escapes removed.
+	Default string
+	// RawDefault is the default value as written, including any quotes and escapes.
+	RawDefault string
+}
+
+// String is the `<name>[=<default value>]` form of the declaration, see Format, in a Dockerfile using the default
+// escape character.
+func (d ArgDeclaration) String() string {
+	return d.Format(DefaultEscapeCharacter)
+}
+
+// Format is the `<name>[=<default value>]` form of the declaration, in a Dockerfile using the given escape character.
+// The raw default value is used if it is still consistent with Default, otherwise it is re-quoted.
+func (d ArgDeclaration) Format(escapeCharacter rune) string {
+	if !d.HasDefault {
+		return d.Name
+	}
+	val := d.RawDefault
+	if !IsWord(val, escapeCharacter) || Unquote(val, escapeCharacter) != d.Default {
+		val = Quote(d.Default, escapeCharacter)
+	}
+	return d.Name + "=" + val
+}
+
+// ArgInstruction declares build arguments which can be passed in at build time.
+// See: https://docs.docker.com/engine/reference/builder/#arg
 type ArgInstruction struct {
-	Name       string
-	DefaultVal string
+	Node
+
+	// Declarations are the build arguments declared by the instruction, in order of appearance.
+	Declarations []ArgDeclaration
 }
 
 func (*ArgInstruction) Type() Type {
 	return ARG
 }
 
-func (*ArgInstruction) Flags() map[string]string {
+func (*ArgInstruction) Flags() []Flag {
 	return nil
 }
 
 func (i *ArgInstruction) Arguments() Arguments {
-	arg := i.Name
-	if i.DefaultVal != "" {
-		arg += "=" + i.DefaultVal
+	args := make([]string, 0, len(i.Declarations))
+	for _, d := range i.Declarations {
+		args = append(args, d.String())
 	}
 	return Arguments{
-		List: []string{arg},
+		List: args,
 	}
 }
@@ -0,0 +1,15 @@
+package statement
+
+// BadStatement is a region of the Dockerfile which could not be parsed, produced when parsing with error recovery.
+type BadStatement struct {
+	Node
+
+	// Lines are the original lines of the region.
+	Lines []string
+	// Err is the reason the region could not be parsed.
+	Err error
+}
+
+func (*BadStatement) Type() Type {
+	return BadType
+}
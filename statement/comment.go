@@ -7,6 +7,8 @@ import (
 const commentToken = "#"
 
 type Comment struct {
+	Node
+
 	// Lines are the lines of the comment (including leading whitespace), minus the "#" token.
 	Lines []string
 }
@@ -0,0 +1,71 @@
+package statement
+
+// CopyInstruction copies files from the build context, another build stage or an image into the image.
+// See: https://docs.docker.com/engine/reference/builder/#copy
+type CopyInstruction struct {
+	Node
+
+	// From is the build stage, image or named context to copy from (`--from`), the build context if empty.
+	From string
+	// Chown is the `<user>[:<group>]` which will own the copied files (`--chown`).
+	Chown string
+	// Chmod is the permissions of the copied files (`--chmod`).
+	Chmod string
+	// Link is whether the files are copied into an independent layer (`--link`).
+	Link bool
+	// Parents is whether the parent directories of the sources are preserved (`--parents`).
+	Parents bool
+	// Exclude are the patterns of paths which are excluded from the copy (`--exclude`).
+	Exclude []string
+	// FlagOrder are the names of the flags in the order they were written, which Flags follows.
+	FlagOrder []string
+
+	// Sources are the paths to copy, as written.
+	Sources []string
+	// Dest is the destination path, as written.
+	Dest string
+	// Execable is whether the paths were written in the JSON array form, which allows whitespace in paths.
+	Execable bool
+	// Heredocs are the here-documents which are copied as sources.
+	Heredocs []Heredoc
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*CopyInstruction) Type() Type {
+	return COPY
+}
+
+// Flags are all of the flags of the instruction, in the order they were written, see FlagOrder.
+func (i *CopyInstruction) Flags() []Flag {
+	var flags []Flag
+	if i.From != "" {
+		flags = append(flags, Flag{Name: "from", Value: i.From})
+	}
+	if i.Chown != "" {
+		flags = append(flags, Flag{Name: "chown", Value: i.Chown})
+	}
+	if i.Chmod != "" {
+		flags = append(flags, Flag{Name: "chmod", Value: i.Chmod})
+	}
+	if i.Link {
+		flags = append(flags, Flag{Name: "link", IsBool: true})
+	}
+	if i.Parents {
+		flags = append(flags, Flag{Name: "parents", IsBool: true})
+	}
+	for _, pattern := range i.Exclude {
+		flags = append(flags, Flag{Name: "exclude", Value: pattern})
+	}
+	return orderFlags(flags, i.FlagOrder)
+}
+
+func (i *CopyInstruction) Arguments() Arguments {
+	return Arguments{
+		List:     append(append([]string(nil), i.Sources...), i.Dest),
+		Execable: i.Execable,
+		Heredocs: i.Heredocs,
+	}
+}
@@ -1,6 +1,8 @@
 package statement
 
 type EnvInstruction struct {
+	Node
+
 	Env      map[string]string
 	KeyOrder []string
 }
@@ -9,7 +11,7 @@ func (*EnvInstruction) Type() Type {
 	return ENV
 }
 
-func (*EnvInstruction) Flags() map[string]string {
+func (*EnvInstruction) Flags() []Flag {
 	return nil
 }
 
@@ -0,0 +1,93 @@
+package statement
+
+import "strconv"
+
+// Protocol is the transport protocol of an exposed port.
+type Protocol string
+
+const (
+	TCP  Protocol = "tcp"
+	UDP  Protocol = "udp"
+	SCTP Protocol = "sctp"
+)
+
+// Port is a single exposed port, e.g. `80/tcp`.
+type Port struct {
+	Number   uint16
+	Protocol Protocol
+}
+
+func (p Port) String() string {
+	return strconv.Itoa(int(p.Number)) + "/" + string(p.Protocol)
+}
+
+// PortSpec is a port or an inclusive range of ports with a protocol, e.g. `80`, `8000-8010/udp`.
+type PortSpec struct {
+	// Raw is the spec as written.
+	Raw string
+	// Start is the first port of the range, or the only port.
+	Start uint16
+	// End is the last port of the range, equal to Start for a single port.
+	End uint16
+	// Protocol is the protocol of the ports, TCP unless specified.
+	// It is empty if the spec contains variable references which have not been resolved.
+	Protocol Protocol
+}
+
+// Ports are the individual ports of the spec, nil if the spec has not been resolved.
+func (s PortSpec) Ports() []Port {
+	if s.Protocol == "" {
+		return nil
+	}
+	ports := make([]Port, 0, int(s.End)-int(s.Start)+1)
+	for n := int(s.Start); n <= int(s.End); n++ {
+		ports = append(ports, Port{Number: uint16(n), Protocol: s.Protocol})
+	}
+	return ports
+}
+
+// String is the spec as written, or `<start>[-<end>][/<protocol>]` if it was not parsed.
+func (s PortSpec) String() string {
+	if s.Raw != "" {
+		return s.Raw
+	}
+	spec := strconv.Itoa(int(s.Start))
+	if s.End != s.Start {
+		spec += "-" + strconv.Itoa(int(s.End))
+	}
+	if s.Protocol != "" && s.Protocol != TCP {
+		spec += "/" + string(s.Protocol)
+	}
+	return spec
+}
+
+// ExposeInstruction documents the ports on which the container listens.
+// See: https://docs.docker.com/engine/reference/builder/#expose
+type ExposeInstruction struct {
+	Node
+
+	// Ports are the exposed port specs, in order of appearance.
+	Ports []PortSpec
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*ExposeInstruction) Type() Type {
+	return EXPOSE
+}
+
+func (*ExposeInstruction) Flags() []Flag {
+	return nil
+}
+
+func (i *ExposeInstruction) Arguments() Arguments {
+	args := make([]string, 0, len(i.Ports))
+	for _, p := range i.Ports {
+		args = append(args, p.String())
+	}
+	return Arguments{
+		List: args,
+	}
+}
@@ -0,0 +1,64 @@
+package statement
+
+import "strings"
+
+// Flag is a flag passed to an instruction, e.g. `--from=build` or `--link`.
+type Flag struct {
+	Name  string
+	Value string
+	// IsBool is whether the flag was given without a value, e.g. `--link`.
+	IsBool bool
+}
+
+// String is the `--<name>[=<value>]` form of the flag, see Format, in a Dockerfile using the default escape character.
+func (f Flag) String() string {
+	return f.Format(DefaultEscapeCharacter)
+}
+
+// Format is the `--<name>[=<value>]` form of the flag, in a Dockerfile using the given escape character.
+// The value is quoted if it contains whitespace, quotes or the escape character.
+func (f Flag) Format(escapeCharacter rune) string {
+	if f.IsBool {
+		return "--" + f.Name
+	}
+	val := f.Value
+	if strings.ContainsAny(val, " \t\n\"'") || strings.ContainsRune(val, escapeCharacter) {
+		val = Quote(val, escapeCharacter)
+	}
+	return "--" + f.Name + "=" + val
+}
+
+// writtenOrder is the order in which to render the given names, which are in canonical order, so that they follow
+// the names in the order they were written. Names may repeat, and names which were not written come last.
+func writtenOrder(names []string, written []string) []int {
+	order := make([]int, 0, len(names))
+	used := make([]bool, len(names))
+	for _, w := range written {
+		for i, name := range names {
+			if !used[i] && name == w {
+				order = append(order, i)
+				used[i] = true
+				break
+			}
+		}
+	}
+	for i := range names {
+		if !used[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// orderFlags orders the given flags, which are in canonical order, as written, see writtenOrder.
+func orderFlags(flags []Flag, written []string) []Flag {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = f.Name
+	}
+	var ordered []Flag
+	for _, i := range writtenOrder(names, written) {
+		ordered = append(ordered, flags[i])
+	}
+	return ordered
+}
@@ -1,6 +1,8 @@
 package statement
 
 type FromInstruction struct {
+	Node
+
 	Platform string
 	Image    string
 	Alias    string
@@ -10,11 +12,9 @@ func (*FromInstruction) Type() Type {
 	return FROM
 }
 
-func (i *FromInstruction) Flags() map[string]string {
+func (i *FromInstruction) Flags() []Flag {
 	if i.Platform != "" {
-		return map[string]string{
-			"platform": i.Platform,
-		}
+		return []Flag{{Name: "platform", Value: i.Platform}}
 	}
 	return nil
 }
@@ -28,3 +28,9 @@ func (i *FromInstruction) Arguments() Arguments {
 		List: args,
 	}
 }
+
+// ImageReference parses the image of the instruction, see ParseImageReference.
+// The image may also be the name of an earlier build stage, or `scratch`.
+func (i *FromInstruction) ImageReference() (ImageReference, error) {
+	return ParseImageReference(i.Image)
+}
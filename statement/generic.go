@@ -1,6 +1,8 @@
 package statement
 
 type GenericInstruction struct {
+	Node
+
 	InstructionType Type
 
 	Args Arguments
@@ -14,7 +16,7 @@ func (i *GenericInstruction) Type() Type {
 	return i.InstructionType
 }
 
-func (*GenericInstruction) Flags() map[string]string {
+func (*GenericInstruction) Flags() []Flag {
 	return nil
 }
 
@@ -0,0 +1,82 @@
+package statement
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HealthcheckInstruction configures how the container's health is checked.
+// See: https://docs.docker.com/engine/reference/builder/#healthcheck
+type HealthcheckInstruction struct {
+	Node
+
+	// Interval is the time between checks (`--interval`). Zero durations and retries use Docker's defaults.
+	Interval time.Duration
+	// Timeout is the time after which a single check is considered to have failed (`--timeout`).
+	Timeout time.Duration
+	// StartPeriod is the initialization time during which failures are not counted (`--start-period`).
+	StartPeriod time.Duration
+	// StartInterval is the time between checks during the start period (`--start-interval`).
+	StartInterval time.Duration
+	// Retries is the number of consecutive failures after which the container is unhealthy (`--retries`).
+	Retries int
+	// FlagOrder are the names of the flags in the order they were written, which Flags follows.
+	FlagOrder []string
+
+	// None disables any health check inherited from the base image, i.e. `HEALTHCHECK NONE`.
+	None bool
+	// Cmd is the command which checks the container's health, in exec or shell form. Nil if None is set.
+	Cmd *GenericInstruction
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*HealthcheckInstruction) Type() Type {
+	return HEALTHCHECK
+}
+
+// Flags are all of the flags of the instruction, in the order they were written, see FlagOrder.
+func (i *HealthcheckInstruction) Flags() []Flag {
+	var flags []Flag
+	for _, d := range []struct {
+		name string
+		val  time.Duration
+	}{
+		{"interval", i.Interval},
+		{"timeout", i.Timeout},
+		{"start-period", i.StartPeriod},
+		{"start-interval", i.StartInterval},
+	} {
+		if d.val != 0 {
+			flags = append(flags, Flag{Name: d.name, Value: FormatDuration(d.val)})
+		}
+	}
+	if i.Retries != 0 {
+		flags = append(flags, Flag{Name: "retries", Value: strconv.Itoa(i.Retries)})
+	}
+	return orderFlags(flags, i.FlagOrder)
+}
+
+// Arguments are `NONE`, or the arguments of the nested CMD.
+func (i *HealthcheckInstruction) Arguments() Arguments {
+	if i.None || i.Cmd == nil {
+		return Arguments{List: []string{"NONE"}}
+	}
+	return i.Cmd.Arguments()
+}
+
+// FormatDuration formats the duration like time.Duration.String, minus any redundant trailing zero units,
+// e.g. `5m` rather than `5m0s`.
+func FormatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,23 @@
+package statement
+
+// Heredoc is a here-document passed to a RUN, COPY or ADD instruction, e.g.
+//
+//	RUN <<EOF
+//	echo hello
+//	EOF
+//
+// See: https://docs.docker.com/engine/reference/builder/#here-documents
+type Heredoc struct {
+	// Name is the delimiter word of the heredoc, minus any quotes, e.g. `EOF`.
+	// The body is terminated by a line consisting solely of the name. COPY and ADD use it as the source file name.
+	Name string
+	// Delimiter is the heredoc redirection as it appears in the instruction's arguments, e.g. `<<-"EOF"`.
+	Delimiter string
+	// Body is the content of the heredoc, each line terminated by a newline. If Chomp is set, leading tabs have
+	// already been stripped.
+	Body string
+	// Expand is whether variables in the body are expanded, which is disabled by quoting the delimiter word.
+	Expand bool
+	// Chomp is whether leading tabs are stripped from the lines of the body, i.e. the `<<-` form was used.
+	Chomp bool
+}
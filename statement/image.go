@@ -0,0 +1,132 @@
+package statement
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DockerHubRegistry is the registry of image references which do not name one, e.g. `golang`.
+	DockerHubRegistry = "docker.io"
+	// DockerHubOfficialNamespace is the namespace of Docker Hub repositories with a single path component, e.g. `golang`.
+	DockerHubOfficialNamespace = "library"
+	// DefaultTag is the tag of image references with neither a tag nor a digest.
+	DefaultTag = "latest"
+
+	// maxImageNameLength is the maximum length of the registry and repository of an image reference.
+	maxImageNameLength = 255
+)
+
+// The grammar of image references.
+// See: https://github.com/distribution/reference/blob/main/reference.go
+var (
+	registryMatcher = regexp.MustCompile(`^(?:` +
+		`(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*` + // host name
+		`|` + // or
+		`\[[a-fA-F0-9:]+\]` + // IPv6 address
+		`)(?::[0-9]+)?$`) // optional port
+	pathComponentMatcher = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagMatcher           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestMatcher        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+)
+
+// ImageReference is a reference to a container image, e.g. `ghcr.io/org/app:1.0` or `golang@sha256:<hex>`.
+type ImageReference struct {
+	// Registry is the host, and optionally port, of the registry, empty if the reference does not name one.
+	Registry string
+	// Repository is the path of the repository within the registry, e.g. `org/app`.
+	Repository string
+	// Tag is the tag of the image, empty if there is none.
+	Tag string
+	// Digest is the content digest of the image, e.g. `sha256:<hex>`, empty if there is none.
+	Digest string
+}
+
+/*
+ParseImageReference parses a reference to a container image of the form:
+
+	[<registry>/]<repository>[:<tag>][@<digest>]
+
+The first component of the path is the registry if it contains a `.` or a `:`, or is `localhost`.
+The reference is kept as written, see Normalized for the fully-qualified form.
+References containing variables must be resolved before they can be parsed, see Resolve.
+*/
+func ParseImageReference(s string) (ImageReference, error) {
+	var ref ImageReference
+	if s == "" {
+		return ref, fmt.Errorf("invalid image reference: empty")
+	}
+	name := s
+	if i := strings.Index(name, "@"); i != -1 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !digestMatcher.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest %q in image reference %q", ref.Digest, s)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i != -1 && !strings.Contains(name[i+1:], "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		if !tagMatcher.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag %q in image reference %q", ref.Tag, s)
+		}
+	}
+	if len(name) > maxImageNameLength {
+		return ref, fmt.Errorf("invalid image reference %q: name is longer than %d characters", s, maxImageNameLength)
+	}
+
+	ref.Repository = name
+	if i := strings.Index(name, "/"); i != -1 {
+		if first := name[:i]; strings.ContainsAny(first, ".:") || first == "localhost" || strings.ToLower(first) != first {
+			if !registryMatcher.MatchString(first) {
+				return ref, fmt.Errorf("invalid registry %q in image reference %q", first, s)
+			}
+			ref.Registry, ref.Repository = first, name[i+1:]
+		}
+	}
+	for _, component := range strings.Split(ref.Repository, "/") {
+		if pathComponentMatcher.MatchString(component) {
+			continue
+		}
+		if strings.ToLower(component) != component {
+			return ref, fmt.Errorf("invalid image reference %q: repository name must be lowercase", s)
+		}
+		return ref, fmt.Errorf("invalid repository %q in image reference %q", ref.Repository, s)
+	}
+	return ref, nil
+}
+
+// Name is the registry, if any, and repository of the reference, e.g. `ghcr.io/org/app`.
+func (r ImageReference) Name() string {
+	if r.Registry == "" {
+		return r.Repository
+	}
+	return r.Registry + "/" + r.Repository
+}
+
+// String is the reference in the form in which it was parsed.
+func (r ImageReference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// Normalized is the fully-qualified form of the reference, as resolved by Docker, e.g.
+// `golang` is `docker.io/library/golang:latest` and `index.docker.io/org/app` is `docker.io/org/app:latest`.
+func (r ImageReference) Normalized() ImageReference {
+	switch r.Registry {
+	case "", "index.docker.io", "registry-1.docker.io":
+		r.Registry = DockerHubRegistry
+	}
+	if r.Registry == DockerHubRegistry && !strings.Contains(r.Repository, "/") {
+		r.Repository = DockerHubOfficialNamespace + "/" + r.Repository
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = DefaultTag
+	}
+	return r
+}
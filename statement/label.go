@@ -0,0 +1,88 @@
+package statement
+
+// Label is a single key-value pair of image metadata.
+type Label struct {
+	// Key is the name of the label, with any quotes and escapes removed.
+	Key string
+	// Value is the value of the label, with any quotes and escapes removed.
+	Value string
+
+	// RawKey is the key as written, including any quotes and escapes.
+	RawKey string
+	// RawValue is the value as written, including any quotes and escapes.
+	RawValue string
+}
+
+// String is the `<key>=<value>` form of the label, see Format, in a Dockerfile using the default escape character.
+func (l Label) String() string {
+	return l.Format(DefaultEscapeCharacter)
+}
+
+// Format is the `<key>=<value>` form of the label, in a Dockerfile using the given escape character.
+// The raw key and value are used if they are still consistent with Key and Value, otherwise they are re-quoted.
+func (l Label) Format(escapeCharacter rune) string {
+	key := l.RawKey
+	if key == "" || !IsWord(key, escapeCharacter) || Unquote(key, escapeCharacter) != l.Key {
+		key = Quote(l.Key, escapeCharacter)
+	}
+	val := l.RawValue
+	if !IsWord(val, escapeCharacter) || Unquote(val, escapeCharacter) != l.Value {
+		val = Quote(l.Value, escapeCharacter)
+	}
+	return key + "=" + val
+}
+
+// LabelInstruction adds metadata to the image.
+// See: https://docs.docker.com/engine/reference/builder/#label
+type LabelInstruction struct {
+	Node
+
+	// Labels are the labels declared by the instruction, in order of appearance.
+	Labels []Label
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*LabelInstruction) Type() Type {
+	return LABEL
+}
+
+func (*LabelInstruction) Flags() []Flag {
+	return nil
+}
+
+func (i *LabelInstruction) Arguments() Arguments {
+	args := make([]string, 0, len(i.Labels))
+	for _, l := range i.Labels {
+		args = append(args, l.String())
+	}
+	return Arguments{
+		List: args,
+	}
+}
+
+// Get returns the value of the last label declared with the given key, and whether it was declared.
+func (i *LabelInstruction) Get(key string) (string, bool) {
+	for j := len(i.Labels) - 1; j >= 0; j-- {
+		if i.Labels[j].Key == key {
+			return i.Labels[j].Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value of every label declared with the given key, appending a new label if there were none.
+func (i *LabelInstruction) Set(key, value string) {
+	found := false
+	for j := range i.Labels {
+		if i.Labels[j].Key == key {
+			i.Labels[j].Value = value
+			found = true
+		}
+	}
+	if !found {
+		i.Labels = append(i.Labels, Label{Key: key, Value: value})
+	}
+}
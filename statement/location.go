@@ -0,0 +1,87 @@
+package statement
+
+import "fmt"
+
+// Position is a location in the original Dockerfile.
+// Lines and columns are 1-indexed, columns are counted in bytes.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Range is a span of the original Dockerfile.
+// Start is the position of the first character, End is the position immediately after the last character.
+type Range struct {
+	Start Position
+	End   Position
+}
+
+func (r Range) String() string {
+	return r.Start.String() + "-" + r.End.String()
+}
+
+// Segment is a part of a SourceMap's text which was taken from a single line of the original Dockerfile.
+type Segment struct {
+	// Offset is the byte offset of the start of the segment within the text.
+	Offset int
+	// Start is the position of the start of the segment in the original Dockerfile.
+	Start Position
+}
+
+// SourceMap maps byte offsets within text which was assembled from several lines of the original Dockerfile,
+// e.g. the arguments of an instruction spanning continuation lines, back to positions in the original Dockerfile.
+type SourceMap struct {
+	// Text is the assembled text.
+	Text string
+	// Segments are the parts of the text, in order of offset.
+	Segments []Segment
+}
+
+// Position is the position in the original Dockerfile of the byte at the given offset within the text,
+// or the zero Position if it is unknown.
+func (m SourceMap) Position(offset int) Position {
+	if offset < 0 || offset > len(m.Text) {
+		return Position{}
+	}
+	for i := len(m.Segments) - 1; i >= 0; i-- {
+		if seg := m.Segments[i]; seg.Offset <= offset {
+			return Position{Line: seg.Start.Line, Column: seg.Start.Column + offset - seg.Offset}
+		}
+	}
+	return Position{}
+}
+
+// Node is embedded in every parsed statement, recording where it came from in the original Dockerfile.
+type Node struct {
+	// Range spans all of the physical lines of the statement, including continuations and interstitial comments.
+	Range Range
+	// ArgsMap maps the arguments of a parsed instruction, i.e. the text following the instruction name
+	// with line continuations and interstitial comments removed, back to the original Dockerfile.
+	ArgsMap SourceMap
+}
+
+// Location is the span of the original Dockerfile from which the statement was parsed.
+// Statements which were not parsed have a zero Range.
+func (n *Node) Location() Range {
+	return n.Range
+}
+
+// SetLocation records the span of the original Dockerfile from which the statement was parsed.
+func (n *Node) SetLocation(r Range) {
+	n.Range = r
+}
+
+// ArgumentsMap maps the arguments of the instruction back to the original Dockerfile.
+// Statements which were not parsed, or which are not instructions, have an empty SourceMap.
+func (n *Node) ArgumentsMap() SourceMap {
+	return n.ArgsMap
+}
+
+// SetArgumentsMap records where the arguments of the instruction came from in the original Dockerfile.
+func (n *Node) SetArgumentsMap(m SourceMap) {
+	n.ArgsMap = m
+}
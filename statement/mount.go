@@ -0,0 +1,189 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+)
+
+type MountType string
+
+const (
+	BindMountType   MountType = "bind"
+	CacheMountType  MountType = "cache"
+	TmpfsMountType  MountType = "tmpfs"
+	SecretMountType MountType = "secret"
+	SSHMountType    MountType = "ssh"
+)
+
+// Mount is a filesystem mount available to a RUN instruction (`--mount`).
+// Option values are kept as written, since they may reference build args.
+// See: https://docs.docker.com/engine/reference/builder/#run---mount
+type Mount interface {
+	MountType() MountType
+	// String renders the mount as the value of a `--mount` flag, e.g. `type=cache,target=/root/.cache`.
+	String() string
+}
+
+// BindMount mounts a directory of the build context, a build stage or an image.
+type BindMount struct {
+	Target    string
+	Source    string
+	From      string
+	ReadWrite bool
+	// OptionOrder are the keys of the options in the order they were written, which String follows.
+	OptionOrder []string
+}
+
+func (*BindMount) MountType() MountType {
+	return BindMountType
+}
+
+func (m *BindMount) String() string {
+	return renderMountOptions(BindMountType, m.OptionOrder,
+		"target", m.Target,
+		"source", m.Source,
+		"from", m.From,
+		"rw", boolOption(m.ReadWrite))
+}
+
+// CacheMount mounts a directory which is persisted between builds.
+type CacheMount struct {
+	ID       string
+	Target   string
+	ReadOnly bool
+	// Sharing is one of `shared`, `private` or `locked`.
+	Sharing string
+	From    string
+	Source  string
+	Mode    string
+	UID     string
+	GID     string
+	// OptionOrder are the keys of the options in the order they were written, which String follows.
+	OptionOrder []string
+}
+
+func (*CacheMount) MountType() MountType {
+	return CacheMountType
+}
+
+func (m *CacheMount) String() string {
+	return renderMountOptions(CacheMountType, m.OptionOrder,
+		"id", m.ID,
+		"target", m.Target,
+		"ro", boolOption(m.ReadOnly),
+		"sharing", m.Sharing,
+		"from", m.From,
+		"source", m.Source,
+		"mode", m.Mode,
+		"uid", m.UID,
+		"gid", m.GID)
+}
+
+// TmpfsMount mounts a tmpfs.
+type TmpfsMount struct {
+	Target string
+	Size   string
+	// OptionOrder are the keys of the options in the order they were written, which String follows.
+	OptionOrder []string
+}
+
+func (*TmpfsMount) MountType() MountType {
+	return TmpfsMountType
+}
+
+func (m *TmpfsMount) String() string {
+	return renderMountOptions(TmpfsMountType, m.OptionOrder,
+		"target", m.Target,
+		"size", m.Size)
+}
+
+// SecretMount mounts a secret file, or exposes it as an environment variable.
+type SecretMount struct {
+	ID       string
+	Target   string
+	Source   string
+	Env      string
+	Required bool
+	Mode     string
+	UID      string
+	GID      string
+	// OptionOrder are the keys of the options in the order they were written, which String follows.
+	OptionOrder []string
+}
+
+func (*SecretMount) MountType() MountType {
+	return SecretMountType
+}
+
+func (m *SecretMount) String() string {
+	return renderMountOptions(SecretMountType, m.OptionOrder,
+		"id", m.ID,
+		"target", m.Target,
+		"source", m.Source,
+		"env", m.Env,
+		"required", boolOption(m.Required),
+		"mode", m.Mode,
+		"uid", m.UID,
+		"gid", m.GID)
+}
+
+// SSHMount mounts an SSH agent socket.
+type SSHMount struct {
+	ID       string
+	Target   string
+	Required bool
+	Mode     string
+	UID      string
+	GID      string
+	// OptionOrder are the keys of the options in the order they were written, which String follows.
+	OptionOrder []string
+}
+
+func (*SSHMount) MountType() MountType {
+	return SSHMountType
+}
+
+func (m *SSHMount) String() string {
+	return renderMountOptions(SSHMountType, m.OptionOrder,
+		"id", m.ID,
+		"target", m.Target,
+		"required", boolOption(m.Required),
+		"mode", m.Mode,
+		"uid", m.UID,
+		"gid", m.GID)
+}
+
+func boolOption(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
+
+// renderMountOptions renders the given key-value pairs of mount options as CSV, omitting empty values.
+// The options, including the type, are rendered in the order they were written, see writtenOrder.
+// The type comes first if it was not written, i.e. for bind mounts.
+func renderMountOptions(t MountType, written []string, kvs ...string) string {
+	var keys, values []string
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if kvs[i+1] != "" {
+			keys = append(keys, kvs[i])
+			values = append(values, kvs[i+1])
+		}
+	}
+	keys = append([]string{"type"}, keys...)
+	values = append([]string{string(t)}, values...)
+	if !strings.Contains(","+strings.Join(written, ",")+",", ",type,") {
+		written = append([]string{"type"}, written...)
+	}
+	fields := make([]string, 0, len(keys))
+	for _, i := range writtenOrder(keys, written) {
+		fields = append(fields, keys[i]+"="+values[i])
+	}
+	buf := bytes.Buffer{}
+	w := csv.NewWriter(&buf)
+	w.Write(fields)
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,31 @@
+package statement
+
+// OnBuildInstruction registers a trigger instruction, which is executed when the image is used as the base of
+// another build.
+// See: https://docs.docker.com/engine/reference/builder/#onbuild
+type OnBuildInstruction struct {
+	Node
+
+	// Trigger is the instruction to execute in the downstream build.
+	Trigger Instruction
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*OnBuildInstruction) Type() Type {
+	return ONBUILD
+}
+
+func (*OnBuildInstruction) Flags() []Flag {
+	return nil
+}
+
+// Arguments are the arguments of the trigger instruction.
+func (i *OnBuildInstruction) Arguments() Arguments {
+	if i.Trigger == nil {
+		return Arguments{}
+	}
+	return i.Trigger.Arguments()
+}
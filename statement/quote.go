@@ -0,0 +1,78 @@
+package statement
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultEscapeCharacter is the escape character of Dockerfiles which do not set the `escape` parser directive.
+const DefaultEscapeCharacter = '\\'
+
+// Unquote removes shell-style quotes and escapes from a word, e.g. `"John Doe"` becomes `John Doe`.
+// Within double quotes only `"`, `$` and the escape character may be escaped; single quotes preserve everything literally.
+func Unquote(raw string, escapeCharacter rune) string {
+	var sb strings.Builder
+	var quote rune
+	escaped := false
+	for _, c := range raw {
+		switch {
+		case escaped:
+			if quote == '"' && c != '"' && c != '$' && c != escapeCharacter {
+				sb.WriteRune(escapeCharacter)
+			}
+			sb.WriteRune(c)
+			escaped = false
+		case c == escapeCharacter && quote != '\'':
+			escaped = true
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == quote:
+			quote = 0
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	if escaped {
+		sb.WriteRune(escapeCharacter)
+	}
+	return sb.String()
+}
+
+// IsWord is whether raw is a single shell word, i.e. all of its whitespace is quoted or escaped and all of its quotes are terminated.
+func IsWord(raw string, escapeCharacter rune) bool {
+	var quote rune
+	escaped := false
+	for _, c := range raw {
+		switch {
+		case escaped:
+			escaped = false
+		case c == escapeCharacter && quote != '\'':
+			escaped = true
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == quote:
+			quote = 0
+		case quote == 0 && unicode.IsSpace(c):
+			return false
+		}
+	}
+	return quote == 0 && !escaped
+}
+
+// Quote double-quotes a word if it is empty or contains whitespace, quotes, `=` or the escape character,
+// such that Unquote returns the original.
+func Quote(s string, escapeCharacter rune) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\"'=") && !strings.ContainsRune(s, escapeCharacter) {
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range s {
+		if c == '"' || c == escapeCharacter {
+			sb.WriteRune(escapeCharacter)
+		}
+		sb.WriteRune(c)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,59 @@
+package statement
+
+// RunInstruction executes a command while building the image.
+// See: https://docs.docker.com/engine/reference/builder/#run
+type RunInstruction struct {
+	Node
+
+	// Mounts are the filesystem mounts available to the command (`--mount`).
+	Mounts []Mount
+	// Network is the network mode of the command (`--network`), one of `default`, `none` or `host`.
+	Network string
+	// Security is the security mode of the command (`--security`), one of `sandbox` or `insecure`.
+	Security string
+	// FlagOrder are the names of the flags in the order they were written, which Flags follows.
+	FlagOrder []string
+
+	// Command is the command in shell form, as written minus line continuations. Empty in exec form.
+	Command string
+	// Exec is the command in exec form, i.e. written as a JSON array. Nil in shell form.
+	Exec []string
+	// Heredocs are the here-documents referenced by the shell-form command.
+	Heredocs []Heredoc
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*RunInstruction) Type() Type {
+	return RUN
+}
+
+// Flags are all of the flags of the instruction, in the order they were written, see FlagOrder.
+func (i *RunInstruction) Flags() []Flag {
+	var flags []Flag
+	for _, m := range i.Mounts {
+		flags = append(flags, Flag{Name: "mount", Value: m.String()})
+	}
+	if i.Network != "" {
+		flags = append(flags, Flag{Name: "network", Value: i.Network})
+	}
+	if i.Security != "" {
+		flags = append(flags, Flag{Name: "security", Value: i.Security})
+	}
+	return orderFlags(flags, i.FlagOrder)
+}
+
+func (i *RunInstruction) Arguments() Arguments {
+	if i.Exec != nil {
+		return Arguments{
+			List:     i.Exec,
+			Execable: true,
+		}
+	}
+	return Arguments{
+		List:     []string{i.Command},
+		Heredocs: i.Heredocs,
+	}
+}
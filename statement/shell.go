@@ -0,0 +1,29 @@
+package statement
+
+// ShellInstruction overrides the shell which runs the shell-form commands of the remainder of the stage.
+// See: https://docs.docker.com/engine/reference/builder/#shell
+type ShellInstruction struct {
+	Node
+
+	// Shell is the executable and arguments which the shell-form command is appended to, e.g. `["/bin/sh", "-c"]`.
+	Shell []string
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*ShellInstruction) Type() Type {
+	return SHELL
+}
+
+func (*ShellInstruction) Flags() []Flag {
+	return nil
+}
+
+func (i *ShellInstruction) Arguments() Arguments {
+	return Arguments{
+		List:     i.Shell,
+		Execable: true,
+	}
+}
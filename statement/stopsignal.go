@@ -0,0 +1,28 @@
+package statement
+
+// StopSignalInstruction sets the system call signal which is sent to the container to exit.
+// See: https://docs.docker.com/engine/reference/builder/#stopsignal
+type StopSignalInstruction struct {
+	Node
+
+	// Signal is the signal name, e.g. `SIGKILL`, or number, e.g. `9`, as written.
+	Signal string
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*StopSignalInstruction) Type() Type {
+	return STOPSIGNAL
+}
+
+func (*StopSignalInstruction) Flags() []Flag {
+	return nil
+}
+
+func (i *StopSignalInstruction) Arguments() Arguments {
+	return Arguments{
+		List: []string{i.Signal},
+	}
+}
@@ -5,6 +5,7 @@ type Type string
 const (
 	ADD         Type = "ADD"
 	ARG         Type = "ARG"
+	BadType     Type = "<bad>"
 	CMD         Type = "CMD"
 	CommentType Type = "#"
 	COPY        Type = "COPY"
@@ -71,6 +72,8 @@ var Known = map[Type]bool{
 
 type Statement interface {
 	Type() Type
+	// Location is the span of the original Dockerfile from which the statement was parsed.
+	Location() Range
 }
 
 type Arguments struct {
@@ -78,12 +81,15 @@ type Arguments struct {
 	List []string
 	// Whether the args can be passed individually to `exec` or need to be interpreted as a whole by the shell.
 	Execable bool
+	// Heredocs referenced by the arguments, in order of appearance.
+	Heredocs []Heredoc
 }
 
 type Instruction interface {
 	Statement
-	// Flags are the flags passed to the instruction.
-	Flags() map[string]string
+	// Flags are the flags passed to the instruction, in the order they were written.
+	// Repeatable flags, e.g. `--mount`, may appear more than once.
+	Flags() []Flag
 	// Arguments are the arguments passed to the command.
 	Arguments() Arguments
 }
@@ -94,7 +100,11 @@ func (Blank) Type() Type {
 	return Type("")
 }
 
-func (Blank) Flags() map[string]string {
+func (Blank) Location() Range {
+	return Range{}
+}
+
+func (Blank) Flags() []Flag {
 	return nil
 }
 
@@ -104,16 +114,5 @@ func (Blank) Arguments() Arguments {
 
 type TODO GenericInstruction
 type CmdInstruction GenericInstruction
-type CopyInstruction GenericInstruction
 type EntrypointInstruction GenericInstruction
-type ExposeInstruction TODO
-type HealthcheckInstruction TODO
-type LabelInstruction TODO
 type MaintainerInstruction TODO
-type OnBuildInstruction TODO
-type RunInstruction TODO
-type ShellInstruction TODO
-type StopSignalInstruction TODO
-type UserInstruction TODO
-type VolumeInstruction TODO
-type WorkdirInstruction TODO
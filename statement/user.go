@@ -0,0 +1,58 @@
+package statement
+
+// UserInstruction sets the user, and optionally the group, which runs the remainder of the stage and the container.
+// See: https://docs.docker.com/engine/reference/builder/#user
+type UserInstruction struct {
+	Node
+
+	// User is the user name or UID, with any quotes and escapes removed.
+	User string
+	// Group is the group name or GID, with any quotes and escapes removed.
+	// It is empty if not specified, in which case the user's primary group is used.
+	Group string
+
+	// RawUser is the user as written, including any quotes and escapes.
+	RawUser string
+	// RawGroup is the group as written, including any quotes and escapes.
+	RawGroup string
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*UserInstruction) Type() Type {
+	return USER
+}
+
+func (*UserInstruction) Flags() []Flag {
+	return nil
+}
+
+func (i *UserInstruction) Arguments() Arguments {
+	return Arguments{
+		List: []string{i.FormatUser(DefaultEscapeCharacter)},
+	}
+}
+
+// FormatUser is the `<user>[:<group>]` argument, in a Dockerfile using the given escape character.
+// The raw user and group are used if they are still consistent with User and Group, otherwise they are re-quoted.
+func (i *UserInstruction) FormatUser(escapeCharacter rune) string {
+	user := i.RawUser
+	if user == "" || !IsWord(user, escapeCharacter) || Unquote(user, escapeCharacter) != i.User {
+		user = Quote(i.User, escapeCharacter)
+	}
+	if i.Group == "" {
+		return user
+	}
+	group := i.RawGroup
+	if group == "" || !IsWord(group, escapeCharacter) || Unquote(group, escapeCharacter) != i.Group {
+		group = Quote(i.Group, escapeCharacter)
+	}
+	return user + ":" + group
+}
+
+// IsRoot is whether the user is the superuser, i.e. `root` or UID 0.
+func (i *UserInstruction) IsRoot() bool {
+	return i.User == "root" || i.User == "0"
+}
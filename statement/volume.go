@@ -0,0 +1,31 @@
+package statement
+
+// VolumeInstruction declares mount points for externally mounted volumes.
+// See: https://docs.docker.com/engine/reference/builder/#volume
+type VolumeInstruction struct {
+	Node
+
+	// Paths are the mount points, as written.
+	Paths []string
+	// Execable is whether the paths were written in the JSON array form, which allows whitespace in paths.
+	Execable bool
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*VolumeInstruction) Type() Type {
+	return VOLUME
+}
+
+func (*VolumeInstruction) Flags() []Flag {
+	return nil
+}
+
+func (i *VolumeInstruction) Arguments() Arguments {
+	return Arguments{
+		List:     i.Paths,
+		Execable: i.Execable,
+	}
+}
@@ -0,0 +1,53 @@
+package statement
+
+import "strings"
+
+// WorkdirInstruction sets the working directory of the remainder of the stage and the container.
+// See: https://docs.docker.com/engine/reference/builder/#workdir
+type WorkdirInstruction struct {
+	Node
+
+	// Path is the working directory, with any quotes and escapes removed.
+	// Relative paths are relative to the previous working directory.
+	Path string
+	// RawPath is the working directory as written, including any quotes and escapes.
+	RawPath string
+
+	// Lines are all of the input lines of the statement, minus newline escape characters and leading/trailing whitespace.
+	// This includes the instruction name in the first line, as well as any comment lines.
+	Lines []string
+}
+
+func (*WorkdirInstruction) Type() Type {
+	return WORKDIR
+}
+
+func (*WorkdirInstruction) Flags() []Flag {
+	return nil
+}
+
+func (i *WorkdirInstruction) Arguments() Arguments {
+	return Arguments{
+		List: []string{i.FormatPath(DefaultEscapeCharacter)},
+	}
+}
+
+// FormatPath is the path, in a Dockerfile using the given escape character.
+// The raw path is used if it is still consistent with Path, otherwise it is re-quoted.
+func (i *WorkdirInstruction) FormatPath(escapeCharacter rune) string {
+	if i.RawPath == "" || Unquote(i.RawPath, escapeCharacter) != i.Path {
+		return Quote(i.Path, escapeCharacter)
+	}
+	return i.RawPath
+}
+
+// IsAbs is whether Path is absolute, for either Linux (`/app`) or Windows (`C:\app`) containers.
+// Paths which start with a variable reference are not considered absolute until resolved.
+func (i *WorkdirInstruction) IsAbs() bool {
+	if strings.HasPrefix(i.Path, "/") || strings.HasPrefix(i.Path, `\`) {
+		return true
+	}
+	// Windows drive, e.g. `C:\` or `c:/`
+	return len(i.Path) >= 3 && i.Path[1] == ':' && (i.Path[2] == '\\' || i.Path[2] == '/') &&
+		('a' <= i.Path[0] && i.Path[0] <= 'z' || 'A' <= i.Path[0] && i.Path[0] <= 'Z')
+}